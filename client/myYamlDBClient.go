@@ -26,11 +26,83 @@ func checkIfTableCommand(cmd string) bool {
 		return true
 	case "WRITE-TABLE":
 		return true
+	case "FILTER":
+		return true
+	case "SORT":
+		return true
+	case "AGGREGATE":
+		return true
+	case "READ-ROW":
+		return true
+	case "UPDATE-ROW":
+		return true
+	case "DELETE-ROW":
+		return true
+	case "ALTER-TABLE":
+		return true
+	case "COMPACT-TABLE":
+		return true
+	case "CREATE-INDEX":
+		return true
+	case "DROP-INDEX":
+		return true
+	case "LIST-INDEXES":
+		return true
+	default:
+		return false
+	}
+}
+
+func isQueryCommand(cmd string) bool {
+	switch strings.ToUpper(strings.Fields(cmd)[0]) {
+	case "SELECT", "EXPLAIN":
+		return true
 	default:
 		return false
 	}
 }
 
+// qualifyQueryTables prefixes table names following FROM and JOIN in query with db name,
+// unless they already name their database, quoted text is left as is
+func qualifyQueryTables(query string, dbName string) string {
+	var qualified strings.Builder
+	previousWord := ""
+	for i := 0; i < len(query); {
+		char := query[i]
+		if char == '\'' || char == '"' {
+			end := i + 1
+			for end < len(query) && query[end] != char {
+				end++
+			}
+			if end < len(query) {
+				end++
+			}
+			qualified.WriteString(query[i:end])
+			previousWord = ""
+			i = end
+			continue
+		}
+		if strings.ContainsRune(" \t\n\r(),", rune(char)) {
+			qualified.WriteByte(char)
+			i++
+			continue
+		}
+
+		end := i
+		for end < len(query) && !strings.ContainsRune(" \t\n\r(),'\"", rune(query[end])) {
+			end++
+		}
+		word := query[i:end]
+		if (strings.EqualFold(previousWord, "FROM") || strings.EqualFold(previousWord, "JOIN")) && !strings.Contains(word, ":") {
+			word = dbName + ":" + word
+		}
+		qualified.WriteString(word)
+		previousWord = word
+		i = end
+	}
+	return qualified.String()
+}
+
 func main() {
 	var host = "127.0.0.1"
 	var port = strconv.Itoa(common.DBPort)
@@ -52,16 +124,31 @@ func main() {
 		fmt.Printf("Failed to get connection: (%v)\n", err)
 		os.Exit(1)
 	}
+	responseReader := bufio.NewReader(conn)
+
+	// announce protocol version before sending any command
+	if err := common.WriteFrame(conn, common.HandshakeHeader(), nil); err != nil {
+		fmt.Printf("Failed to send handshake: (%v)\n", err)
+		os.Exit(1)
+	}
+	if status, message, err := common.ReadFrame(responseReader); err != nil || status != common.ProtocolOK {
+		fmt.Printf("Failed handshake with server: (%v) %s\n", err, message)
+		os.Exit(1)
+	}
+
+	// read in input from stdin
+	reader := bufio.NewReader(os.Stdin)
 	for {
-		// read in input from stdin
-		reader := bufio.NewReader(os.Stdin)
 		fmt.Print("Text to send: ")
 		text, err := reader.ReadString('\n')
+		if err == io.EOF {
+			return
+		}
 		if err != nil {
 			fmt.Printf("Cannot read: (%v)\n", err)
 			continue
 		}
-		if text == "\n" {
+		if strings.TrimSpace(text) == "" {
 			fmt.Println("No input provided")
 			continue
 		}
@@ -74,76 +161,106 @@ func main() {
 				continue
 			}
 			if strings.ToUpper(cmdPieces[0]) != "LIST-TABLES" && len(cmdPieces) > 1 && !strings.Contains(cmdPieces[1], ":") {
-				text = strings.Replace(text, cmdPieces[1], dbName+":"+cmdPieces[1], -1)
+				cmdPieces[1] = dbName + ":" + cmdPieces[1]
+				text = strings.Join(cmdPieces, " ") + "\n"
 			} else if len(cmdPieces) == 1 && strings.ToUpper(cmdPieces[0]) == "LIST-TABLES" {
 				text = strings.Trim(text, "\n") + " " + dbName + "\n"
 			}
 		}
 
+		if isQueryCommand(text) {
+			if dbName := strings.Trim(os.Getenv("DB_NAME"), "\n"); dbName != "" {
+				text = qualifyQueryTables(text, dbName)
+			}
+		}
+
+		command, payload, err := buildRequest(text)
+		if err != nil {
+			fmt.Println(err.Error())
+			continue
+		}
+
 		// send to socket
-		fmt.Fprintf(conn, writeTableCommand(text))
+		if err := common.WriteFrame(conn, command, payload); err != nil {
+			fmt.Printf("Failed to send command: (%v)\n", err)
+			os.Exit(1)
+		}
 		// listen for reply
-		message := readOutput(conn)
-		if strings.HasPrefix(strings.ToUpper(text), "USE-DB") {
-			fmt.Println("DEFAULT DB SET TO: " + message)
-			os.Setenv("DB_NAME", message)
+		status, message, err := common.ReadFrame(responseReader)
+		if err != nil {
+			fmt.Printf("Failed to read reply: (%v)\n", err)
+			os.Exit(1)
+		}
+		if status == common.ProtocolOK && strings.HasPrefix(strings.ToUpper(text), "USE-DB") {
+			fmt.Println("DEFAULT DB SET TO: " + string(message))
+			os.Setenv("DB_NAME", string(message))
 			continue
 		}
-		fmt.Println(message)
+		fmt.Println(string(message))
 	}
 }
 
-func readOutput(reader io.Reader) string {
-	var text string
-	var bytesRead int
-	bufferReader := bufio.NewReader(reader)
-
-	// Find length of message received
-	text, err := bufferReader.ReadString('\n')
-	if err != nil {
-		return text
-	}
-	messageLength, err := strconv.Atoi(strings.Trim(text, "\n"))
-	if err != nil {
-		return ""
-	}
-	text = ""
-
-	// Now read rest of the message
-	for bytesRead < messageLength {
-		bytesRead++
-		dataRead, err := bufferReader.ReadByte()
-		if err != nil {
-			break
-		}
-		text += string(dataRead)
-	}
-	return text
+// documentArguments maps commands sending a document to their number of arguments,
+// document-file-location is always the last argument
+var documentArguments = map[string]int{
+	"WRITE-TABLE": 3,
+	"UPDATE-ROW":  4,
 }
 
-func writeTableCommand(commmandText string) string {
+// buildRequest splits command text into the command and its payload,
+// payload is the content of document or schema file named in command
+func buildRequest(commmandText string) (string, []byte, error) {
 
 	cmdPieces := strings.Fields(commmandText)
-	if strings.ToUpper(cmdPieces[0]) != "WRITE-TABLE" {
-		return commmandText + "\n"
+	if isQueryCommand(commmandText) {
+		// queries are sent as typed, quoted text may hold whitespace
+		return strings.TrimSpace(commmandText), nil, nil
+	}
+	if strings.ToUpper(cmdPieces[0]) == "CREATE-TABLE" {
+		return schemaRequest(cmdPieces)
 	}
-	if len(cmdPieces) != 3 {
-		return commmandText + "\n"
+
+	argsCount, ok := documentArguments[strings.ToUpper(cmdPieces[0])]
+	if !ok || len(cmdPieces) != argsCount {
+		return strings.Join(cmdPieces, " "), nil, nil
 	}
 
 	tableName := cmdPieces[1]
-	docName := cmdPieces[2]
+	docName := cmdPieces[argsCount-1]
 
 	// Read the document and verify yaml
 	fileContent, err := ioutil.ReadFile(docName)
 	if err != nil {
-		return cmdPieces[0] + " " + cmdPieces[1] + " NO-DATA\n"
+		return "", nil, fmt.Errorf("CANNOT READ DOCUMENT '%s': (%v)", docName, err)
 	}
 
 	if _, valid := models.ParseYamlRecord(fileContent); !valid {
-		return cmdPieces[0] + " " + cmdPieces[1] + " INVALID-DATA\n"
+		return "", nil, fmt.Errorf("INVALID DOCUMENT '%s'", docName)
 	}
 
 	fmt.Printf("Writing data from file: %s to table: %s\n", docName, tableName)
-	return cmdPieces[0] + " " + cmdPieces[1] + " " + common.EncodeFileContent(fileContent) + "\n"
+	return strings.Join(cmdPieces[:argsCount-1], " "), fileContent, nil
+}
+
+// schemaRequest sends schema-file-location following '--schema' of
+// create-table command as payload
+func schemaRequest(cmdPieces []string) (string, []byte, error) {
+	for i := 1; i < len(cmdPieces)-1; i++ {
+		if strings.ToLower(cmdPieces[i]) != "--schema" {
+			continue
+		}
+
+		// Read the schema and verify yaml
+		schemaName := cmdPieces[i+1]
+		fileContent, err := ioutil.ReadFile(schemaName)
+		if err != nil {
+			return "", nil, fmt.Errorf("CANNOT READ SCHEMA '%s': (%v)", schemaName, err)
+		}
+		if _, valid := models.ParseTableSchema(fileContent); !valid {
+			return "", nil, fmt.Errorf("INVALID SCHEMA '%s'", schemaName)
+		}
+		cmdPieces = append(cmdPieces[:i+1], cmdPieces[i+2:]...)
+		return strings.Join(cmdPieces, " "), fileContent, nil
+	}
+	return strings.Join(cmdPieces, " "), nil, nil
 }
@@ -0,0 +1,307 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Server settings are taken from command line flags, environment variables,
+// a yaml config file and defaults, in that order of precedence.
+// Every setting has a name used as key in config file, e.g. "data_dir",
+// a flag, e.g. "--data-dir", and an environment variable, e.g. "MYYAMLDB_DATA_DIR".
+// Config file is given by "--config <file>" or MYYAMLDB_CONFIG
+
+// Log levels
+const (
+	LogDebug = "debug"
+	LogInfo  = "info"
+	LogWarn  = "warn"
+	LogError = "error"
+)
+
+// LogLevels log levels, least severe first
+var LogLevels = []string{LogDebug, LogInfo, LogWarn, LogError}
+
+// Setting sources
+const (
+	SourceDefault = "default"
+	SourceFile    = "file"
+	SourceEnv     = "env"
+	SourceFlag    = "flag"
+)
+
+const (
+	configFlag = "--config"
+	configEnv  = "MYYAMLDB_CONFIG"
+)
+
+// Config settings of server
+type Config struct {
+	DataDir         string
+	BindAddress     string
+	Port            int
+	LogLevel        string
+	Storage         string
+	CompactInterval time.Duration
+	TableCacheSize  int
+	MaxConnections  int
+	// BackupDir directory backup archives are written to and restored from
+	BackupDir string
+	// ChangeLogDir directory changes are archived to, change log is disabled when empty
+	ChangeLogDir         string
+	ChangeLogSegmentSize int64
+	ChangeLogSegments    int
+
+	// Values of settings as given, by setting name
+	Values map[string]string
+	// Sources of settings, by setting name
+	Sources map[string]string
+}
+
+type configSetting struct {
+	name         string
+	defaultValue string
+	// apply validates value and stores it in config
+	apply func(config *Config, value string) error
+}
+
+var configSettings = []configSetting{
+	{"data_dir", DBLocation, func(config *Config, value string) error {
+		if value == "" {
+			return errors.New("data directory should not be empty")
+		}
+		config.DataDir = value
+		return nil
+	}},
+	{"bind_address", "", func(config *Config, value string) error {
+		config.BindAddress = value
+		return nil
+	}},
+	{"port", strconv.Itoa(DBPort), func(config *Config, value string) error {
+		port, err := strconv.Atoi(value)
+		if err != nil || port < 0 || port > 65535 {
+			return errors.New("DB port should be a number")
+		}
+		config.Port = port
+		return nil
+	}},
+	{"log_level", LogInfo, func(config *Config, value string) error {
+		for _, level := range LogLevels {
+			if strings.ToLower(value) == level {
+				config.LogLevel = level
+				return nil
+			}
+		}
+		return fmt.Errorf("log level should be one of %s", strings.Join(LogLevels, ", "))
+	}},
+	{"storage", "file", func(config *Config, value string) error {
+		config.Storage = value
+		return nil
+	}},
+	{"compact_interval", "0", func(config *Config, value string) error {
+		if value == "0" {
+			config.CompactInterval = 0
+			return nil
+		}
+		interval, err := time.ParseDuration(value)
+		if err != nil || interval < 0 {
+			return errors.New("compact interval should be a duration, e.g. 10m")
+		}
+		config.CompactInterval = interval
+		return nil
+	}},
+	{"table_cache_size", "64", func(config *Config, value string) error {
+		size, err := strconv.Atoi(value)
+		if err != nil || size < 0 {
+			return errors.New("table cache size should be a number of tables")
+		}
+		config.TableCacheSize = size
+		return nil
+	}},
+	{"max_connections", "0", func(config *Config, value string) error {
+		connections, err := strconv.Atoi(value)
+		if err != nil || connections < 0 {
+			return errors.New("max connections should be a number, 0 for no limit")
+		}
+		config.MaxConnections = connections
+		return nil
+	}},
+	{"backup_dir", BackupLocation, func(config *Config, value string) error {
+		if value == "" {
+			return errors.New("backup directory should not be empty")
+		}
+		config.BackupDir = value
+		return nil
+	}},
+	{"change_log_dir", "", func(config *Config, value string) error {
+		config.ChangeLogDir = value
+		return nil
+	}},
+	{"change_log_segment_size", "16777216", func(config *Config, value string) error {
+		size, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || size <= 0 {
+			return errors.New("change log segment size should be a number of bytes")
+		}
+		config.ChangeLogSegmentSize = size
+		return nil
+	}},
+	{"change_log_segments", "0", func(config *Config, value string) error {
+		segments, err := strconv.Atoi(value)
+		if err != nil || segments < 0 {
+			return errors.New("change log segments should be a number, 0 keeps every segment")
+		}
+		config.ChangeLogSegments = segments
+		return nil
+	}},
+}
+
+// ConfigSettingNames names of settings, in the order they are listed
+func ConfigSettingNames() []string {
+	names := make([]string, len(configSettings))
+	for i, setting := range configSettings {
+		names[i] = setting.name
+	}
+	return names
+}
+
+func settingFlag(name string) string {
+	return "--" + strings.Replace(name, "_", "-", -1)
+}
+
+func settingEnv(name string) string {
+	return "MYYAMLDB_" + strings.ToUpper(name)
+}
+
+// ConfigUsage returns flags accepted by LoadConfig
+func ConfigUsage() string {
+	usage := "[<port>] [" + configFlag + " <file>]"
+	for _, setting := range configSettings {
+		usage += fmt.Sprintf(" [%s <%s>]", settingFlag(setting.name), strings.Replace(setting.name, "_", "-", -1))
+	}
+	return usage
+}
+
+// parseConfigArgs returns settings given as flags, by setting name, and config file given,
+// a lone number is taken as port
+func parseConfigArgs(args []string) (map[string]string, string, error) {
+	flags := make(map[string]string)
+	configFile := ""
+	for i := 0; i < len(args); i++ {
+		if !strings.HasPrefix(args[i], "--") {
+			if _, ok := flags["port"]; ok {
+				return nil, "", fmt.Errorf("unexpected argument '%s'", args[i])
+			}
+			flags["port"] = args[i]
+			continue
+		}
+		if i+1 >= len(args) {
+			return nil, "", fmt.Errorf("missing value of '%s'", args[i])
+		}
+		if args[i] == configFlag {
+			configFile = args[i+1]
+			i++
+			continue
+		}
+		known := false
+		for _, setting := range configSettings {
+			if args[i] == settingFlag(setting.name) {
+				flags[setting.name] = args[i+1]
+				known = true
+				break
+			}
+		}
+		if !known {
+			return nil, "", fmt.Errorf("unknown flag '%s'", args[i])
+		}
+		i++
+	}
+	return flags, configFile, nil
+}
+
+// readConfigFile reads settings from yaml config file, by setting name
+func readConfigFile(configFile string) (map[string]string, error) {
+	configData, err := ioutil.ReadFile(configFile)
+	if err != nil {
+		return nil, err
+	}
+	var values map[string]interface{}
+	if err := yaml.Unmarshal(configData, &values); err != nil {
+		return nil, err
+	}
+
+	settings := make(map[string]string)
+	for name, value := range values {
+		known := false
+		for _, setting := range configSettings {
+			if name == setting.name {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return nil, fmt.Errorf("unknown setting '%s'", name)
+		}
+		if value != nil {
+			settings[name] = fmt.Sprint(value)
+		}
+	}
+	return settings, nil
+}
+
+// LoadConfig loads server settings from command line args, environment,
+// config file and defaults
+func LoadConfig(args []string) (*Config, error) {
+	flags, configFile, err := parseConfigArgs(args)
+	if err != nil {
+		return nil, err
+	}
+	if configFile == "" {
+		configFile = os.Getenv(configEnv)
+	}
+	fileSettings := make(map[string]string)
+	if configFile != "" {
+		if fileSettings, err = readConfigFile(configFile); err != nil {
+			return nil, fmt.Errorf("reading config file %s: %v", configFile, err)
+		}
+	}
+
+	config := &Config{Values: make(map[string]string), Sources: make(map[string]string)}
+	for _, setting := range configSettings {
+		value, source := setting.defaultValue, SourceDefault
+		if fileValue, ok := fileSettings[setting.name]; ok {
+			value, source = fileValue, SourceFile
+		}
+		if envValue, ok := os.LookupEnv(settingEnv(setting.name)); ok {
+			value, source = envValue, SourceEnv
+		}
+		if flagValue, ok := flags[setting.name]; ok {
+			value, source = flagValue, SourceFlag
+		}
+		if err := setting.apply(config, value); err != nil {
+			return nil, fmt.Errorf("%s (%s from %s)", err, setting.name, source)
+		}
+		config.Values[setting.name] = value
+		config.Sources[setting.name] = source
+	}
+	return config, nil
+}
+
+// LogEnabled checks if messages of level are logged at configured log level
+func (config *Config) LogEnabled(level string) bool {
+	for _, configured := range LogLevels {
+		if configured == config.LogLevel {
+			return true
+		}
+		if configured == level {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,96 @@
+package common
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Client and server exchange frames in both directions, a frame is
+// "<header-length> <payload-length>\n" followed by header and payload bytes.
+// Request header is the command, payload carries the document of the command if any.
+// Response header is ProtocolOK or ProtocolError, payload carries the output.
+// First frame sent by client is the handshake, its header is "<ProtocolName> <ProtocolVersion>"
+
+// ProtocolName name announced in handshake
+const ProtocolName = "MYYAMLDB"
+
+// ProtocolVersion version of the framed protocol
+const ProtocolVersion = 2
+
+// Response headers
+const (
+	ProtocolOK    = "OK"
+	ProtocolError = "ERROR"
+)
+
+// MaxFrameSize maximum size of header and payload of a frame
+const MaxFrameSize = 64 << 20
+
+// ErrFrameTooLarge returned when a frame exceeds MaxFrameSize
+var ErrFrameTooLarge = errors.New("FRAME TOO LARGE")
+
+// ErrInvalidFrame returned when frame prefix cannot be parsed
+var ErrInvalidFrame = errors.New("INVALID FRAME")
+
+// HandshakeHeader returns header of handshake frame
+func HandshakeHeader() string {
+	return fmt.Sprintf("%s %d", ProtocolName, ProtocolVersion)
+}
+
+// CheckHandshake checks handshake header sent by client
+func CheckHandshake(header string) error {
+	handshake := strings.Fields(header)
+	if len(handshake) != 2 || handshake[0] != ProtocolName {
+		return errors.New("INVALID HANDSHAKE")
+	}
+	if version, err := strconv.Atoi(handshake[1]); err != nil || version != ProtocolVersion {
+		return fmt.Errorf("UNSUPPORTED PROTOCOL VERSION '%s', SERVER SPEAKS VERSION %d", handshake[1], ProtocolVersion)
+	}
+	return nil
+}
+
+// WriteFrame writes header and payload as a frame
+func WriteFrame(writer io.Writer, header string, payload []byte) error {
+	if len(header)+len(payload) > MaxFrameSize {
+		return ErrFrameTooLarge
+	}
+	frame := make([]byte, 0, len(header)+len(payload)+32)
+	frame = append(frame, fmt.Sprintf("%d %d\n", len(header), len(payload))...)
+	frame = append(frame, header...)
+	frame = append(frame, payload...)
+	_, err := writer.Write(frame)
+	return err
+}
+
+// ReadFrame reads a frame and returns its header and payload
+func ReadFrame(reader *bufio.Reader) (string, []byte, error) {
+	prefix, err := reader.ReadString('\n')
+	if err != nil {
+		return "", nil, err
+	}
+	lengths := strings.Fields(prefix)
+	if len(lengths) != 2 {
+		return "", nil, ErrInvalidFrame
+	}
+	headerLength, err := strconv.Atoi(lengths[0])
+	if err != nil || headerLength < 0 {
+		return "", nil, ErrInvalidFrame
+	}
+	payloadLength, err := strconv.Atoi(lengths[1])
+	if err != nil || payloadLength < 0 {
+		return "", nil, ErrInvalidFrame
+	}
+	if headerLength+payloadLength > MaxFrameSize {
+		return "", nil, ErrFrameTooLarge
+	}
+
+	frame := make([]byte, headerLength+payloadLength)
+	if _, err := io.ReadFull(reader, frame); err != nil {
+		return "", nil, err
+	}
+	return string(frame[:headerLength]), frame[headerLength:], nil
+}
@@ -0,0 +1,100 @@
+package common
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Row-id strategies available to tables
+const (
+	RowIDSequence = "sequence"
+	RowIDULID     = "ulid"
+	RowIDUUID     = "uuid"
+)
+
+// DefaultRowIDStrategy strategy used by tables which do not choose one
+const DefaultRowIDStrategy = RowIDULID
+
+// RowIDPrefix prefix of every row-id
+const RowIDPrefix = "row_id_"
+
+// RowIDGenerator generates row-ids for a table
+type RowIDGenerator interface {
+	// NextRowID returns a new row-id, sequence is the number of
+	// row-ids generated for the table before this one
+	NextRowID(sequence uint64) (string, error)
+}
+
+var (
+	rowIDGeneratorsMutex sync.RWMutex
+	rowIDGenerators      = map[string]RowIDGenerator{
+		RowIDSequence: sequenceGenerator{},
+		RowIDULID:     ulidGenerator{},
+		RowIDUUID:     uuidGenerator{},
+	}
+)
+
+// RegisterRowIDGenerator makes a row-id strategy available to tables
+func RegisterRowIDGenerator(strategy string, generator RowIDGenerator) {
+	rowIDGeneratorsMutex.Lock()
+	defer rowIDGeneratorsMutex.Unlock()
+	rowIDGenerators[strategy] = generator
+}
+
+// GetRowIDGenerator returns generator for row-id strategy
+func GetRowIDGenerator(strategy string) (RowIDGenerator, bool) {
+	rowIDGeneratorsMutex.RLock()
+	defer rowIDGeneratorsMutex.RUnlock()
+	generator, ok := rowIDGenerators[strategy]
+	return generator, ok
+}
+
+// sequenceGenerator generates monotonic per-table row-ids
+type sequenceGenerator struct{}
+
+func (sequenceGenerator) NextRowID(sequence uint64) (string, error) {
+	return RowIDPrefix + strconv.FormatUint(sequence+1, 10), nil
+}
+
+// ulidGenerator generates lexicographically sortable row-ids,
+// 48 bits of milliseconds since epoch followed by 80 random bits,
+// encoded in Crockford's base32
+type ulidGenerator struct{}
+
+const crockfordBase32 = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+func (ulidGenerator) NextRowID(uint64) (string, error) {
+	var id [16]byte
+	binary.BigEndian.PutUint64(id[:8], uint64(time.Now().UnixNano()/int64(time.Millisecond))<<16)
+	if _, err := rand.Read(id[6:]); err != nil {
+		return "", err
+	}
+
+	// 128 bits are encoded as 26 characters of 5 bits, first character carries 3 bits
+	encoded := make([]byte, 26)
+	high := binary.BigEndian.Uint64(id[:8])
+	low := binary.BigEndian.Uint64(id[8:])
+	for i := 25; i >= 0; i-- {
+		encoded[i] = crockfordBase32[low&0x1f]
+		low = low>>5 | high<<59
+		high >>= 5
+	}
+	return RowIDPrefix + string(encoded), nil
+}
+
+// uuidGenerator generates random (version 4) uuid row-ids
+type uuidGenerator struct{}
+
+func (uuidGenerator) NextRowID(uint64) (string, error) {
+	var id [16]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		return "", err
+	}
+	id[6] = id[6]&0x0f | 0x40
+	id[8] = id[8]&0x3f | 0x80
+	return RowIDPrefix + fmt.Sprintf("%x-%x-%x-%x-%x", id[0:4], id[4:6], id[6:8], id[8:10], id[10:]), nil
+}
@@ -0,0 +1,230 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/sushilkm/myYamlDB/models"
+)
+
+// Aggregate command format is
+// aggregate <db-name>:<table-name> <fn>(<column>) [, <fn>(<column>) ...] [group-by <column> [, <column> ...]]
+// fn is one of count, sum, avg, min and max, count(*) counts rows.
+// Null and missing values are left out, sum of int values is an int and turns into
+// a float once a float value is added, avg is a float. Without group-by a single row
+// aggregates every row of table, with group-by a row is listed for every combination
+// of group column values, ordered by group column values.
+// Columns can address nested data, e.g. "sum(order.total)"
+
+var aggregatePattern = regexp.MustCompile(`^([A-Za-z]+)\((.+)\)$`)
+
+type aggregateFunction struct {
+	function string
+	// column values are aggregated of, empty for count(*)
+	column string
+}
+
+func (fn aggregateFunction) label() string {
+	if fn.column == "" {
+		return fn.function + "(*)"
+	}
+	return fn.function + "(" + fn.column + ")"
+}
+
+// parseAggregateFunctions parses aggregate functions separated by commas or spaces
+func parseAggregateFunctions(args []string) ([]aggregateFunction, error) {
+	text := strings.Replace(strings.Join(args, " "), ",", " ", -1)
+	if len(strings.Fields(text)) == 0 {
+		return nil, errors.New("NO AGGREGATE FUNCTION PROVIDED")
+	}
+
+	var functions []aggregateFunction
+	listed := make(map[string]bool)
+	for _, fnText := range strings.Fields(text) {
+		fnPieces := aggregatePattern.FindStringSubmatch(fnText)
+		if fnPieces == nil {
+			return nil, fmt.Errorf("INVALID AGGREGATE '%s', EXPECTED <FUNCTION>(<COLUMN>)", fnText)
+		}
+		fn := aggregateFunction{function: strings.ToLower(fnPieces[1]), column: fnPieces[2]}
+		if _, err := models.NewAggregator(fn.function); err != nil {
+			return nil, err
+		}
+		if fn.column == "*" {
+			if fn.function != models.AggregateCount {
+				return nil, fmt.Errorf("INVALID AGGREGATE '%s', ONLY COUNT TAKES '*'", fnText)
+			}
+			fn.column = ""
+		} else if err := models.ValidateColumnPath(fn.column); err != nil {
+			return nil, fmt.Errorf("INVALID COLUMN '%s'", fn.column)
+		}
+		if listed[fn.label()] {
+			return nil, fmt.Errorf("AGGREGATE '%s' IS LISTED TWICE", fn.label())
+		}
+		listed[fn.label()] = true
+		functions = append(functions, fn)
+	}
+	return functions, nil
+}
+
+// parseAggregate parses aggregate functions and group columns of command arguments
+func parseAggregate(args []string) ([]aggregateFunction, []string, error) {
+	fnArgs := args
+	var groupColumns []string
+	for i, arg := range args {
+		if strings.ToLower(arg) != "group-by" {
+			continue
+		}
+		fnArgs = args[:i]
+		columnNames := strings.Fields(strings.Replace(strings.Join(args[i+1:], " "), ",", " ", -1))
+		if len(columnNames) == 0 {
+			return nil, nil, errors.New("NO GROUP COLUMN PROVIDED")
+		}
+		columns, err := parseColumnList(strings.Join(columnNames, ","))
+		if err != nil {
+			return nil, nil, err
+		}
+		groupColumns = columns
+		break
+	}
+
+	functions, err := parseAggregateFunctions(fnArgs)
+	if err != nil {
+		return nil, nil, err
+	}
+	return functions, groupColumns, nil
+}
+
+type aggregateGroup struct {
+	values      []interface{}
+	aggregators []*models.Aggregator
+}
+
+// aggregateTable aggregates rows of table into a table holding a row for every group
+func aggregateTable(tbl *models.DataTable, functions []aggregateFunction, groupColumns []string) (*models.DataTable, error) {
+	groups := make(map[string]*aggregateGroup)
+	var groupKeys []string
+	newGroup := func(key string, values []interface{}) *aggregateGroup {
+		group := &aggregateGroup{values: values}
+		for _, fn := range functions {
+			aggregator, _ := models.NewAggregator(fn.function)
+			group.aggregators = append(group.aggregators, aggregator)
+		}
+		groups[key] = group
+		groupKeys = append(groupKeys, key)
+		return group
+	}
+	// without group-by every row is aggregated in a single group, even when table is empty
+	if len(groupColumns) == 0 {
+		newGroup("", nil)
+	}
+
+	for _, rowID := range tbl.OrderedRowIDs() {
+		record := tbl.Records[rowID]
+		values := make([]interface{}, len(groupColumns))
+		keyPieces := make([]string, len(groupColumns))
+		for i, column := range groupColumns {
+			if value, found := record.Lookup(column); found {
+				values[i] = value.ColumnData
+			}
+			keyPieces[i] = indexKey(values[i])
+		}
+		key := strings.Join(keyPieces, "\x00")
+		group, ok := groups[key]
+		if !ok {
+			group = newGroup(key, values)
+		}
+
+		for i, fn := range functions {
+			var value interface{} = true
+			if fn.column != "" {
+				value = nil
+				if column, found := record.Lookup(fn.column); found {
+					value = column.ColumnData
+				}
+			}
+			if err := group.aggregators[i].Add(value); err != nil {
+				if err == models.ErrNotNumeric {
+					return nil, fmt.Errorf("COLUMN '%s' HAS NON-NUMERIC VALUE '%s'", fn.column, models.DataColumn{ColumnData: value})
+				}
+				return nil, fmt.Errorf("COLUMN '%s' HAS VALUES WHICH CANNOT BE COMPARED", fn.column)
+			}
+		}
+	}
+
+	sort.SliceStable(groupKeys, func(i, j int) bool {
+		first, second := groups[groupKeys[i]], groups[groupKeys[j]]
+		for column := range groupColumns {
+			if result := compareValues(first.values[column], second.values[column]); result != 0 {
+				return result < 0
+			}
+		}
+		return false
+	})
+
+	result := &models.DataTable{
+		Records: make(map[string]models.DataRecord, len(groupKeys)),
+		Columns: append([]string(nil), groupColumns...),
+	}
+	for _, fn := range functions {
+		result.Columns = append(result.Columns, fn.label())
+	}
+	for groupIndex, key := range groupKeys {
+		group := groups[key]
+		record := models.DataRecord{Columns: make(map[string]models.DataColumn, len(result.Columns))}
+		for i, column := range groupColumns {
+			record.Columns[column] = models.DataColumn{ColumnData: group.values[i]}
+		}
+		for i, fn := range functions {
+			record.Columns[fn.label()] = models.DataColumn{ColumnData: group.aggregators[i].Result()}
+		}
+		rowID := strconv.Itoa(groupIndex + 1)
+		result.Records[rowID] = record
+		result.RowIDs = append(result.RowIDs, rowID)
+	}
+	return result, nil
+}
+
+func (db *DBEngine) aggregate() (string, error) {
+	if len(db.cmdArgs) < 1 {
+		return "", errors.New("INVALID TABLE-NAME, CANNOT AGGREGATE TABLE")
+	}
+
+	functions, groupColumns, err := parseAggregate(db.cmdArgs[1:])
+	if err != nil {
+		return "", err
+	}
+
+	tablePieces, err := db.parseTableName()
+	if err != nil {
+		return "", err
+	}
+	lock := tableLockFor(tablePieces)
+	if err := lock.RLock(); err != nil {
+		return "", err
+	}
+	tbl, err := loadTableFile(tablePieces)
+	lock.RUnlock()
+	if err != nil {
+		return "", err
+	}
+
+	columns := append([]string(nil), groupColumns...)
+	for _, fn := range functions {
+		if fn.column != "" {
+			columns = append(columns, fn.column)
+		}
+	}
+	if err := checkColumnsExist(tbl, columns); err != nil {
+		return "", err
+	}
+
+	result, err := aggregateTable(tbl, functions, groupColumns)
+	if err != nil {
+		return "", err
+	}
+	return result.ToString(), nil
+}
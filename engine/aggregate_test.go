@@ -0,0 +1,108 @@
+package engine
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/sushilkm/myYamlDB/models"
+)
+
+func TestParseAggregate(t *testing.T) {
+	tests := []struct {
+		args         string
+		functions    []string
+		groupColumns []string
+		err          string
+	}{
+		{args: "count(*)", functions: []string{"count(*)"}},
+		{args: "COUNT(*), Sum(age)", functions: []string{"count(*)", "sum(age)"}},
+		{args: "avg(age) max(order.total)", functions: []string{"avg(age)", "max(order.total)"}},
+		{args: "min(age) group-by city", functions: []string{"min(age)"}, groupColumns: []string{"city"}},
+		{args: "count(*) GROUP-BY city, address.zip", functions: []string{"count(*)"}, groupColumns: []string{"city", "address.zip"}},
+		{args: "count(name) group-by city,tags[0]", functions: []string{"count(name)"}, groupColumns: []string{"city", "tags[0]"}},
+		{args: "", err: "NO AGGREGATE FUNCTION PROVIDED"},
+		{args: "group-by city", err: "NO AGGREGATE FUNCTION PROVIDED"},
+		{args: "count(*) group-by", err: "NO GROUP COLUMN PROVIDED"},
+		{args: "count(*) group-by city,city", err: "COLUMN 'city' IS LISTED TWICE"},
+		{args: "age", err: "INVALID AGGREGATE 'age', EXPECTED <FUNCTION>(<COLUMN>)"},
+		{args: "median(age)", err: "UNKNOWN AGGREGATE FUNCTION 'median'"},
+		{args: "sum(*)", err: "INVALID AGGREGATE 'sum(*)', ONLY COUNT TAKES '*'"},
+		{args: "sum(tags[)", err: "INVALID COLUMN 'tags['"},
+		{args: "sum(age), SUM(age)", err: "AGGREGATE 'sum(age)' IS LISTED TWICE"},
+	}
+	for _, test := range tests {
+		functions, groupColumns, err := parseAggregate(strings.Fields(test.args))
+		if test.err != "" {
+			if err == nil || err.Error() != test.err {
+				t.Errorf("parseAggregate(%q) returned error %v, expected %q", test.args, err, test.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseAggregate(%q) failed: %v", test.args, err)
+			continue
+		}
+		var labels []string
+		for _, fn := range functions {
+			labels = append(labels, fn.label())
+		}
+		if !reflect.DeepEqual(labels, test.functions) {
+			t.Errorf("parseAggregate(%q) aggregates %v, expected %v", test.args, labels, test.functions)
+		}
+		if !reflect.DeepEqual(groupColumns, test.groupColumns) {
+			t.Errorf("parseAggregate(%q) groups by %v, expected %v", test.args, groupColumns, test.groupColumns)
+		}
+	}
+}
+
+func TestAggregateTable(t *testing.T) {
+	tbl, ok := models.ParseYaml([]byte(`
+row_id_1: {name: ann, age: 30, score: 1.5, city: pune}
+row_id_2: {name: bob, age: 25, city: goa, tags: y}
+row_id_3: {name: cid, age: 35, score: 2, city: pune}
+row_id_4: {name: dan, age: null}
+row_id_5: {name: eve, age: 20, city: goa, tags: [x]}
+`))
+	if !ok {
+		t.Fatal("ParseYaml failed")
+	}
+	tests := []struct {
+		args   string
+		output string
+		err    string
+	}{
+		{args: "count(*)", output: "count(*)\n5"},
+		// null and missing values are left out
+		{args: "count(age), count(score)", output: "count(age)|count(score)\n4|2"},
+		{args: "sum(age), avg(age)", output: "sum(age)|avg(age)\n110|27.5"},
+		{args: "sum(score)", output: "sum(score)\n3.5"},
+		{args: "min(age), max(age), min(name), max(name)", output: "min(age)|max(age)|min(name)|max(name)\n20|35|ann|eve"},
+		// rows missing group columns form a group, ordered first
+		{args: "count(*), sum(age) group-by city", output: "city|count(*)|sum(age)\n|1|\ngoa|2|45\npune|2|65"},
+		{args: "max(name) group-by city, tags[0]", output: "city|tags[0]|max(name)\n||dan\ngoa||bob\ngoa|x|eve\npune||cid"},
+		{args: "sum(name)", err: "COLUMN 'name' HAS NON-NUMERIC VALUE 'ann'"},
+		{args: "max(tags)", err: "COLUMN 'tags' HAS VALUES WHICH CANNOT BE COMPARED"},
+	}
+	for _, test := range tests {
+		functions, groupColumns, err := parseAggregate(strings.Fields(test.args))
+		if err != nil {
+			t.Errorf("parseAggregate(%q) failed: %v", test.args, err)
+			continue
+		}
+		aggregated, err := aggregateTable(tbl, functions, groupColumns)
+		if test.err != "" {
+			if err == nil || err.Error() != test.err {
+				t.Errorf("aggregateTable(%q) returned error %v, expected %q", test.args, err, test.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("aggregateTable(%q) failed: %v", test.args, err)
+			continue
+		}
+		if output := aggregated.ToString(); output != test.output {
+			t.Errorf("aggregateTable(%q) = %q, expected %q", test.args, output, test.output)
+		}
+	}
+}
@@ -0,0 +1,188 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/sushilkm/myYamlDB/models"
+	"gopkg.in/yaml.v2"
+)
+
+// Alter table command format is
+// alter-table <db-name>:<table-name> add-column <column> [--type <type>] [--default <value>] [--required]
+// alter-table <db-name>:<table-name> drop-column <column>
+// alter-table <db-name>:<table-name> rename-column <column> <new-column>
+// existing rows are rewritten to match the altered schema,
+// rows get default value of an added column
+
+func parseAddColumnOptions(columnName string, options []string) (models.ColumnSchema, error) {
+	column := models.ColumnSchema{Name: columnName}
+	for i := 0; i < len(options); i++ {
+		switch strings.ToLower(options[i]) {
+		case "--required":
+			column.Required = true
+			continue
+		case "--type", "--default":
+		default:
+			return column, fmt.Errorf("INVALID OPTION '%s'", options[i])
+		}
+		if i+1 == len(options) {
+			return column, fmt.Errorf("NO VALUE PROVIDED FOR OPTION '%s'", options[i])
+		}
+		if strings.ToLower(options[i]) == "--type" {
+			column.Type = strings.ToLower(options[i+1])
+		} else if err := yaml.Unmarshal([]byte(options[i+1]), &column.Default); err != nil {
+			return column, fmt.Errorf("INVALID DEFAULT VALUE '%s'", options[i+1])
+		}
+		i++
+	}
+	return column, nil
+}
+
+// alterSchema applies alter-table operation to schema and returns
+// the function which alters every existing record accordingly
+func alterSchema(schema *models.TableSchema, operation string, args []string, hasRows bool) (func(models.DataRecord), error) {
+	if len(args) == 0 {
+		return nil, errors.New("NO COLUMN PROVIDED")
+	}
+	columnName := args[0]
+
+	switch operation {
+	case "ADD-COLUMN":
+		if schema.HasColumn(columnName) {
+			return nil, fmt.Errorf("COLUMN '%s' ALREADY EXISTS", columnName)
+		}
+		column, err := parseAddColumnOptions(columnName, args[1:])
+		if err != nil {
+			return nil, err
+		}
+		if column.Required && column.Default == nil && hasRows {
+			return nil, fmt.Errorf("REQUIRED COLUMN '%s' NEEDS A DEFAULT VALUE FOR EXISTING ROWS", columnName)
+		}
+		schema.Columns = append(schema.Columns, column)
+		if err := schema.Validate(); err != nil {
+			return nil, err
+		}
+		return func(record models.DataRecord) {
+			if _, ok := record.Columns[columnName]; !ok && column.Default != nil {
+				record.Columns[columnName] = models.DataColumn{ColumnData: column.Default}
+			}
+		}, nil
+
+	case "DROP-COLUMN":
+		if len(args) != 1 {
+			return nil, errors.New("INVALID NUMBER OF ARGUMENTS")
+		}
+		if !schema.HasColumn(columnName) {
+			return nil, fmt.Errorf("COLUMN '%s' DOES NOT EXISTS", columnName)
+		}
+		schema.DropColumn(columnName)
+		return func(record models.DataRecord) {
+			delete(record.Columns, columnName)
+		}, nil
+
+	case "RENAME-COLUMN":
+		if len(args) != 2 {
+			return nil, errors.New("INVALID NUMBER OF ARGUMENTS")
+		}
+		newColumnName := args[1]
+		if !schema.HasColumn(columnName) {
+			return nil, fmt.Errorf("COLUMN '%s' DOES NOT EXISTS", columnName)
+		}
+		if schema.HasColumn(newColumnName) {
+			return nil, fmt.Errorf("COLUMN '%s' ALREADY EXISTS", newColumnName)
+		}
+		schema.RenameColumn(columnName, newColumnName)
+		return func(record models.DataRecord) {
+			if value, ok := record.Columns[columnName]; ok {
+				record.Columns[newColumnName] = value
+				delete(record.Columns, columnName)
+			}
+		}, nil
+	}
+	return nil, fmt.Errorf("INVALID ALTER-TABLE OPERATION '%s', EXPECTED 'ADD-COLUMN', 'DROP-COLUMN' OR 'RENAME-COLUMN'", operation)
+}
+
+// alterIndexes drops indexes on a dropped column, moves indexes on a renamed column
+// to its new name and rebuilds indexes of the rewritten table
+func alterIndexes(tablePieces []string, operation string, args []string) error {
+	indexes, err := readTableIndexes(tablePieces)
+	if err != nil || indexes == nil {
+		return err
+	}
+
+	var definitions []tableIndex
+	for _, index := range indexes.Indexes {
+		if columnName, _ := models.ColumnPathRoot(index.Column); columnName == args[0] {
+			switch operation {
+			case "DROP-COLUMN":
+				continue
+			case "RENAME-COLUMN":
+				index.Column = args[1] + strings.TrimPrefix(index.Column, args[0])
+			}
+		}
+		definitions = append(definitions, index)
+	}
+	if indexes, err = buildTableIndexes(tablePieces, definitions); err != nil {
+		return err
+	}
+	return writeTableIndexes(tablePieces, indexes)
+}
+
+func (db *DBEngine) alterTable() (string, error) {
+	if len(db.cmdArgs) < 2 {
+		return "", errors.New("INVALID NUMBER OF ARGUMENTS")
+	}
+
+	tablePieces, err := db.parseTableName()
+	if err != nil {
+		return "", err
+	}
+	lock := tableLockFor(tablePieces)
+	if err := lock.Lock(); err != nil {
+		return "", err
+	}
+	defer lock.Unlock()
+
+	if _, err = storage.StatTable(tablePieces[0], tablePieces[1]); os.IsNotExist(err) {
+		return "", errors.New("TABLE DOES NOT EXISTS")
+	}
+	tableData, err := readTableRecords(tablePieces)
+	if err != nil {
+		fmt.Printf("Error while reading table: (%v)\n", err)
+		return "", errors.New(dbEngineError)
+	}
+	tbl, valid := models.ParseYaml(tableData)
+	if !valid {
+		return "", errors.New("INVALID TABLE DATA")
+	}
+	schema, err := readTableSchema(tablePieces)
+	if err != nil {
+		return "", err
+	}
+	if len(schema.Columns) == 0 {
+		// tables created before schemas were introduced learn their columns from existing rows
+		schema.AddColumns(tbl.Columns...)
+	}
+
+	operation := strings.ToUpper(db.cmdArgs[1])
+	alterRecord, err := alterSchema(schema, operation, db.cmdArgs[2:], len(tbl.Records) > 0)
+	if err != nil {
+		return "", err
+	}
+	for _, record := range tbl.Records {
+		alterRecord(record)
+	}
+	tbl.Columns = schema.ColumnNames()
+
+	if err := rewriteTable(tablePieces, tbl, schema); err != nil {
+		return "", err
+	}
+	if err := alterIndexes(tablePieces, operation, db.cmdArgs[2:]); err != nil {
+		fmt.Printf("Error while rebuilding indexes: (%v)\n", err)
+	}
+
+	return fmt.Sprintf(`TABLE '%s:%s' ALTERED.`, tablePieces[0], tablePieces[1]), nil
+}
@@ -0,0 +1,747 @@
+package engine
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sushilkm/myYamlDB/common"
+	"github.com/sushilkm/myYamlDB/models"
+	"gopkg.in/yaml.v2"
+)
+
+// Backup and restore command format is
+// backup-db <db-name> <archive-path>
+// restore-db <archive-path> [<new-db-name>] [--until <position>|<timestamp>|latest]
+// Archive path is a relative path inside the backup directory of the server,
+// absolute paths and paths leaving the backup directory are rejected.
+// Archive records position of the last change archived to change log before the backup,
+// restore-db --until replays changes archived after it on top of the restored database,
+// up to a change log position, up to a RFC 3339 timestamp or up to the latest change.
+// Backup reads every table of database holding a read lock on all of them at once,
+// so the archive is a consistent snapshot while the server keeps serving.
+// Archive is a tar.gz holding a manifest, followed by records, schema and indexes
+// of every table. Archive is streamed to a temporary file renamed in place once it is complete,
+// restore streams files of archive to a staging directory checking them against the manifest,
+// and parses every schema, records and indexes file before installing any table
+
+// backupDir directory backup archives are kept in
+var backupDir = common.BackupLocation
+
+// SetBackupDir sets directory backup archives are kept in, to be called before serving clients
+func SetBackupDir(dir string) {
+	backupDir = dir
+}
+
+// backupArchivePath resolves archive path given by client inside backup directory
+func backupArchivePath(archivePath string) (string, error) {
+	invalidPath := fmt.Errorf("INVALID BACKUP FILE '%s', EXPECTED A RELATIVE PATH INSIDE THE BACKUP DIRECTORY", archivePath)
+	if archivePath == "" || filepath.IsAbs(archivePath) || filepath.VolumeName(archivePath) != "" ||
+		strings.HasPrefix(archivePath, "/") || strings.HasPrefix(archivePath, `\`) {
+		return "", invalidPath
+	}
+	for _, element := range strings.FieldsFunc(archivePath, func(char rune) bool { return char == '/' || char == '\\' }) {
+		if element == ".." {
+			return "", invalidPath
+		}
+	}
+	if filepath.Clean(archivePath) == "." {
+		return "", invalidPath
+	}
+	return filepath.Join(backupDir, filepath.Clean(archivePath)), nil
+}
+
+// backupManifestName name of manifest in archive, always its first entry
+const backupManifestName = "MANIFEST.yaml"
+
+// backupFormat version of archive layout
+const backupFormat = 1
+
+// Table files in archive, "<TABLE-NAME><suffix>"
+const (
+	backupRecordsSuffix = ".tbl"
+	backupSchemaSuffix  = ".schema"
+	backupIndexSuffix   = ".idx"
+)
+
+type backupManifest struct {
+	Format    int    `yaml:"format"`
+	Database  string `yaml:"database"`
+	CreatedAt string `yaml:"created_at"`
+	// ChangeLogPosition position of last change of database included in backup
+	ChangeLogPosition uint64       `yaml:"change_log_position"`
+	Tables            []string     `yaml:"tables"`
+	Files             []backupFile `yaml:"files"`
+}
+
+type backupFile struct {
+	Name   string `yaml:"name"`
+	Size   int64  `yaml:"size"`
+	SHA256 string `yaml:"sha256"`
+}
+
+func checksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// snapshotDatabase reads files of every table of database, holding read locks
+// of all its tables at once, by file name in archive, along with change log position
+// of the snapshot, caller holds the lock of database
+func snapshotDatabase(dbName string) ([]string, map[string][]byte, uint64, error) {
+	tableNames, err := storage.ListTables(dbName)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	sort.Strings(tableNames)
+
+	// tables are locked in the order of their keys, as joins lock the tables they read,
+	// other commands lock a single table
+	for _, tableName := range tableNames {
+		lock := tableLockFor([]string{dbName, tableName})
+		if err := lock.RLock(); err != nil {
+			return nil, nil, 0, err
+		}
+		defer lock.RUnlock()
+	}
+
+	position, err := changeLogPosition(dbName)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	files := make(map[string][]byte)
+	var snapshotTables []string
+	for _, tableName := range tableNames {
+		records, err := storage.ReadRecords(dbName, tableName)
+		if os.IsNotExist(err) {
+			// table was deleted before it was locked
+			continue
+		}
+		if err != nil {
+			return nil, nil, 0, err
+		}
+		schema, err := storage.ReadSchema(dbName, tableName)
+		if err != nil {
+			return nil, nil, 0, err
+		}
+		indexes, err := storage.ReadIndexes(dbName, tableName)
+		if err != nil {
+			return nil, nil, 0, err
+		}
+
+		tableName = strings.ToUpper(tableName)
+		snapshotTables = append(snapshotTables, tableName)
+		files[tableName+backupRecordsSuffix] = records
+		if schema != nil {
+			files[tableName+backupSchemaSuffix] = schema
+		}
+		if indexes != nil {
+			files[tableName+backupIndexSuffix] = indexes
+		}
+	}
+	return snapshotTables, files, position, nil
+}
+
+// countingWriter counts bytes written through it
+type countingWriter struct {
+	writer  io.Writer
+	written int64
+}
+
+func (w *countingWriter) Write(data []byte) (int, error) {
+	n, err := w.writer.Write(data)
+	w.written += int64(n)
+	return n, err
+}
+
+// writeBackupArchive writes archive to archivePath, which is not to exist, and returns its size,
+// archive path is taken before archive is written, so that backups never overwrite each other
+func writeBackupArchive(archivePath string, manifest *backupManifest, files map[string][]byte) (int64, error) {
+	fileNames := make([]string, 0, len(files))
+	for fileName := range files {
+		fileNames = append(fileNames, fileName)
+	}
+	sort.Strings(fileNames)
+	for _, fileName := range fileNames {
+		manifest.Files = append(manifest.Files, backupFile{
+			Name:   fileName,
+			Size:   int64(len(files[fileName])),
+			SHA256: checksum(files[fileName]),
+		})
+	}
+	manifestData, err := yaml.Marshal(manifest)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(archivePath), 0700); err != nil {
+		return 0, err
+	}
+	placeholder, err := os.OpenFile(archivePath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return 0, err
+	}
+	placeholder.Close()
+
+	var archive *countingWriter
+	tempFileName, err := streamTempFile(archivePath, func(w io.Writer) error {
+		archive = &countingWriter{writer: w}
+		gzipWriter := gzip.NewWriter(archive)
+		tarWriter := tar.NewWriter(gzipWriter)
+		modTime := time.Now()
+		writeEntry := func(name string, data []byte) error {
+			header := &tar.Header{Name: name, Mode: 0600, Size: int64(len(data)), ModTime: modTime}
+			if err := tarWriter.WriteHeader(header); err != nil {
+				return err
+			}
+			_, err := tarWriter.Write(data)
+			return err
+		}
+		if err := writeEntry(backupManifestName, manifestData); err != nil {
+			return err
+		}
+		for _, fileName := range fileNames {
+			if err := writeEntry(fileName, files[fileName]); err != nil {
+				return err
+			}
+		}
+		if err := tarWriter.Close(); err != nil {
+			return err
+		}
+		return gzipWriter.Close()
+	})
+	if err == nil {
+		if err = os.Rename(tempFileName, archivePath); err != nil {
+			os.Remove(tempFileName)
+		}
+	}
+	if err == nil {
+		err = syncDir(filepath.Dir(archivePath))
+	}
+	if err != nil {
+		os.Remove(archivePath)
+		return 0, err
+	}
+	return archive.written, nil
+}
+
+func (db *DBEngine) backupDatabase() (string, error) {
+	if len(db.cmdArgs) != 2 {
+		return "", errors.New("INVALID NUMBER OF ARGUMENTS")
+	}
+	dbName, archivePath := strings.ToUpper(db.cmdArgs[0]), db.cmdArgs[1]
+	archiveFileName, err := backupArchivePath(archivePath)
+	if err != nil {
+		return "", err
+	}
+
+	// tables of database are neither created nor deleted while it is backed up
+	lock := lockFor(databaseKey(dbName))
+	if err := lock.Lock(); err != nil {
+		return "", err
+	}
+	defer lock.Unlock()
+
+	exists, err := storage.DatabaseExists(dbName)
+	if err != nil {
+		fmt.Printf("Error while backing up database: (%v)\n", err)
+		return "", errors.New(dbEngineError)
+	}
+	if !exists {
+		return "", errors.New("DB DOES NOT EXISTS")
+	}
+
+	tableNames, files, position, err := snapshotDatabase(dbName)
+	if err != nil {
+		fmt.Printf("Error while backing up database: (%v)\n", err)
+		return "", errors.New(dbEngineError)
+	}
+	manifest := &backupManifest{
+		Format:    backupFormat,
+		Database:  dbName,
+		CreatedAt: time.Now().UTC().Format(time.RFC3339),
+		Tables:    tableNames,
+
+		ChangeLogPosition: position,
+	}
+	archiveSize, err := writeBackupArchive(archiveFileName, manifest, files)
+	if os.IsExist(err) {
+		return "", fmt.Errorf("BACKUP FILE '%s' ALREADY EXISTS", archivePath)
+	}
+	if err != nil {
+		fmt.Printf("Error while writing backup: (%v)\n", err)
+		return "", fmt.Errorf("CANNOT WRITE BACKUP FILE '%s'", archivePath)
+	}
+
+	return fmt.Sprintf("DB '%s' BACKED UP TO '%s'. TABLES: %d, BYTES: %d, CHANGE LOG POSITION: %d",
+		dbName, archivePath, len(tableNames), archiveSize, position), nil
+}
+
+// isBackupFileName checks if name of file in archive is a plain file name
+func isBackupFileName(name string) bool {
+	return name != "" && name != "." && name != ".." && !strings.ContainsAny(name, `:/\`)
+}
+
+// readBackupArchive streams files of archive to stagingDir, checking every file against manifest,
+// and returns manifest
+func readBackupArchive(archivePath string, archiveFileName string, stagingDir string) (*backupManifest, error) {
+	f, err := os.Open(archiveFileName)
+	if err != nil {
+		return nil, fmt.Errorf("CANNOT READ BACKUP FILE '%s'", archivePath)
+	}
+	defer f.Close()
+
+	invalidArchive := fmt.Errorf("INVALID BACKUP FILE '%s'", archivePath)
+	gzipReader, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, invalidArchive
+	}
+	tarReader := tar.NewReader(gzipReader)
+
+	header, err := tarReader.Next()
+	if err != nil || header.Name != backupManifestName {
+		return nil, invalidArchive
+	}
+	manifestData, err := ioutil.ReadAll(io.LimitReader(tarReader, header.Size))
+	if err != nil {
+		return nil, invalidArchive
+	}
+	manifest := &backupManifest{}
+	if err := yaml.Unmarshal(manifestData, manifest); err != nil {
+		return nil, invalidArchive
+	}
+	if manifest.Format != backupFormat {
+		return nil, fmt.Errorf("UNSUPPORTED BACKUP FORMAT %d", manifest.Format)
+	}
+
+	expected := make(map[string]backupFile, len(manifest.Files))
+	for _, file := range manifest.Files {
+		if !isBackupFileName(file.Name) {
+			return nil, invalidArchive
+		}
+		expected[file.Name] = file
+	}
+	extracted := make(map[string]bool)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, invalidArchive
+		}
+		file, ok := expected[header.Name]
+		if !ok || extracted[header.Name] {
+			return nil, errors.New("BACKUP FILES DO NOT MATCH MANIFEST")
+		}
+		extracted[header.Name] = true
+
+		stagedFile, err := os.OpenFile(filepath.Join(stagingDir, file.Name), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if err != nil {
+			fmt.Printf("Error while reading backup: (%v)\n", err)
+			return nil, errors.New(dbEngineError)
+		}
+		hash := sha256.New()
+		size, err := io.Copy(io.MultiWriter(stagedFile, hash), io.LimitReader(tarReader, file.Size+1))
+		if closeErr := stagedFile.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return nil, invalidArchive
+		}
+		if size != file.Size || hex.EncodeToString(hash.Sum(nil)) != file.SHA256 {
+			return nil, fmt.Errorf("CHECKSUM MISMATCH OF BACKUP FILE '%s'", file.Name)
+		}
+	}
+	for _, file := range manifest.Files {
+		if !extracted[file.Name] {
+			return nil, fmt.Errorf("BACKUP FILE '%s' IS MISSING", file.Name)
+		}
+	}
+	return manifest, nil
+}
+
+// readStagedFile reads file staged from archive, nil when archive does not hold it
+func readStagedFile(stagingDir string, fileName string) ([]byte, error) {
+	data, err := ioutil.ReadFile(filepath.Join(stagingDir, fileName))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	return data, err
+}
+
+// checkBackupFiles parses schema, records and indexes of every table staged from archive,
+// one table at a time, archive is to hold no other files
+func checkBackupFiles(manifest *backupManifest, stagingDir string) error {
+	tableFiles := make(map[string]bool)
+	for _, tableName := range manifest.Tables {
+		if !isBackupFileName(tableName) {
+			return fmt.Errorf("INVALID TABLE-NAME '%s' IN BACKUP", tableName)
+		}
+		for _, suffix := range []string{backupRecordsSuffix, backupSchemaSuffix, backupIndexSuffix} {
+			tableFiles[tableName+suffix] = true
+		}
+
+		readError := func(fileName string, err error) error {
+			fmt.Printf("Error while reading backup file %s: (%v)\n", fileName, err)
+			return errors.New(dbEngineError)
+		}
+		schemaData, err := readStagedFile(stagingDir, tableName+backupSchemaSuffix)
+		if err != nil {
+			return readError(tableName+backupSchemaSuffix, err)
+		}
+		if schemaData != nil {
+			schema, valid := models.ParseTableSchema(schemaData)
+			if !valid || schema.Validate() != nil {
+				return fmt.Errorf("INVALID SCHEMA OF TABLE '%s' IN BACKUP", tableName)
+			}
+		}
+		records, err := readStagedFile(stagingDir, tableName+backupRecordsSuffix)
+		if err != nil {
+			return readError(tableName+backupRecordsSuffix, err)
+		}
+		if records == nil {
+			return fmt.Errorf("BACKUP FILE '%s' IS MISSING", tableName+backupRecordsSuffix)
+		}
+		records = []byte(strings.TrimPrefix(string(records), emptyTableMarker))
+		if _, valid := models.ParseYaml(records); !valid {
+			return fmt.Errorf("INVALID RECORDS OF TABLE '%s' IN BACKUP", tableName)
+		}
+		if _, valid := models.ParseYamlRowIDs(records); !valid {
+			return fmt.Errorf("INVALID RECORDS OF TABLE '%s' IN BACKUP", tableName)
+		}
+		indexData, err := readStagedFile(stagingDir, tableName+backupIndexSuffix)
+		if err != nil {
+			return readError(tableName+backupIndexSuffix, err)
+		}
+		if indexData != nil {
+			var indexes tableIndexes
+			if err := yaml.Unmarshal(indexData, &indexes); err != nil {
+				return fmt.Errorf("INVALID INDEXES OF TABLE '%s' IN BACKUP", tableName)
+			}
+		}
+	}
+	for _, file := range manifest.Files {
+		if !tableFiles[file.Name] {
+			return fmt.Errorf("UNEXPECTED BACKUP FILE '%s'", file.Name)
+		}
+	}
+	return nil
+}
+
+// replayTarget limit of changes replayed on top of a backup, every change when both are zero
+type replayTarget struct {
+	position uint64
+	time     time.Time
+}
+
+func parseReplayTarget(until string) (*replayTarget, error) {
+	if strings.ToLower(until) == "latest" {
+		return &replayTarget{}, nil
+	}
+	if position, err := strconv.ParseUint(until, 10, 64); err == nil {
+		// positions start at 1, position 0 would read as the latest change
+		if position == 0 {
+			return nil, errors.New("INVALID --until '0', POSITIONS START AT 1")
+		}
+		return &replayTarget{position: position}, nil
+	}
+	if untilTime, err := time.Parse(time.RFC3339Nano, until); err == nil {
+		return &replayTarget{time: untilTime}, nil
+	}
+	return nil, fmt.Errorf("INVALID --until '%s', EXPECTED A POSITION, A RFC 3339 TIMESTAMP OR 'LATEST'", until)
+}
+
+func (db *DBEngine) restoreDatabase() (string, error) {
+	args := db.cmdArgs
+	var target *replayTarget
+	for i := 0; i < len(args); i++ {
+		if strings.ToLower(args[i]) != "--until" {
+			continue
+		}
+		if i+1 >= len(args) {
+			return "", errors.New("INVALID NUMBER OF ARGUMENTS")
+		}
+		var err error
+		if target, err = parseReplayTarget(args[i+1]); err != nil {
+			return "", err
+		}
+		args = append(args[:i:i], args[i+2:]...)
+		break
+	}
+	if len(args) < 1 || len(args) > 2 {
+		return "", errors.New("INVALID NUMBER OF ARGUMENTS")
+	}
+	archivePath := args[0]
+	archiveFileName, err := backupArchivePath(archivePath)
+	if err != nil {
+		return "", err
+	}
+
+	stagingDir, err := ioutil.TempDir("", "myyamldb-restore")
+	if err != nil {
+		fmt.Printf("Error while restoring database: (%v)\n", err)
+		return "", errors.New(dbEngineError)
+	}
+	defer os.RemoveAll(stagingDir)
+	manifest, err := readBackupArchive(archivePath, archiveFileName, stagingDir)
+	if err != nil {
+		return "", err
+	}
+	if err := checkBackupFiles(manifest, stagingDir); err != nil {
+		return "", err
+	}
+	dbName := strings.ToUpper(manifest.Database)
+	if len(args) == 2 {
+		dbName = strings.ToUpper(args[1])
+	}
+	if dbName == "" || strings.ContainsAny(dbName, `:/\`) || filepath.Base(dbName) != dbName {
+		return "", errors.New("INVALID DB-NAME, CANNOT RESTORE DATABASE")
+	}
+
+	var changes []changeEntry
+	if target != nil {
+		if changes, err = changesToReplay(manifest, target); err != nil {
+			return "", err
+		}
+	}
+
+	lock := lockFor(databaseKey(dbName))
+	if err := lock.Lock(); err != nil {
+		return "", err
+	}
+	defer lock.Unlock()
+
+	exists, err := storage.DatabaseExists(dbName)
+	if err != nil {
+		fmt.Printf("Error while restoring database: (%v)\n", err)
+		return "", errors.New(dbEngineError)
+	}
+	if exists {
+		return "", errors.New("DB ALREADY EXISTS")
+	}
+
+	// restored database is archived as created, followed by its restored tables
+	// and replayed changes, as if they were made by clients
+	archiver := newChangeArchiver(dbName, changeEntry{Operation: changeCreateDatabase})
+	if err := archiveChange(archiver); err != nil {
+		fmt.Printf("Error while logging change: (%v)\n", err)
+		return "", errors.New(dbEngineError)
+	}
+	if err := storage.CreateDatabase(dbName); err != nil {
+		abortChange(archiver)
+		fmt.Printf("Error while restoring database: (%v)\n", err)
+		return "", errors.New(dbEngineError)
+	}
+	for _, tableName := range manifest.Tables {
+		if err := restoreTable(dbName, tableName, stagingDir); err != nil {
+			fmt.Printf("Error while restoring table %s: (%v)\n", tableName, err)
+			dropRestoredDatabase(dbName)
+			return "", errors.New(dbEngineError)
+		}
+	}
+	if target == nil {
+		return fmt.Sprintf("DB '%s' RESTORED FROM '%s'. TABLES: %d", dbName, archivePath, len(manifest.Tables)), nil
+	}
+
+	if err := replayChanges(dbName, changes); err != nil {
+		fmt.Printf("Error while replaying changes: (%v)\n", err)
+		dropRestoredDatabase(dbName)
+		return "", errors.New(dbEngineError)
+	}
+	position := manifest.ChangeLogPosition
+	if len(changes) > 0 {
+		position = changes[len(changes)-1].Position
+	}
+	return fmt.Sprintf("DB '%s' RESTORED FROM '%s'. TABLES: %d, CHANGES REPLAYED: %d, CHANGE LOG POSITION: %d",
+		dbName, archivePath, len(manifest.Tables), len(changes), position), nil
+}
+
+// changesToReplay returns changes of backed up database archived after backup, up to target
+func changesToReplay(manifest *backupManifest, target *replayTarget) ([]changeEntry, error) {
+	log := changeLogFor(manifest.Database)
+	if log == nil {
+		return nil, errors.New("CHANGE LOG IS DISABLED")
+	}
+	changes, err := log.changesAfter(manifest.ChangeLogPosition)
+	if err != nil {
+		fmt.Printf("Error while reading change log: (%v)\n", err)
+		return nil, errors.New(dbEngineError)
+	}
+	if len(changes) > 0 && changes[0].Position != manifest.ChangeLogPosition+1 {
+		return nil, fmt.Errorf("CHANGE LOG STARTS AT POSITION %d, CHANGES AFTER POSITION %d OF BACKUP ARE MISSING",
+			changes[0].Position, manifest.ChangeLogPosition)
+	}
+	if target.position > 0 && target.position < manifest.ChangeLogPosition {
+		return nil, fmt.Errorf("POSITION %d IS BEFORE BACKUP, BACKUP IS AT POSITION %d", target.position, manifest.ChangeLogPosition)
+	}
+
+	aborted := make(map[string]bool)
+	for _, change := range changes {
+		if change.Operation == changeAbort {
+			aborted[change.ID] = true
+		}
+	}
+
+	var replayed []changeEntry
+	for _, change := range changes {
+		if target.position > 0 && change.Position > target.position {
+			break
+		}
+		if !target.time.IsZero() && change.Time.After(target.time) {
+			break
+		}
+		if change.Operation == changeAbort || aborted[change.ID] {
+			// change failed to apply
+			continue
+		}
+		if change.Operation == changeDeleteDatabase || change.Operation == changeCreateDatabase {
+			// database was deleted, later changes belong to another database of the same name
+			break
+		}
+		replayed = append(replayed, change)
+	}
+	lastPosition := manifest.ChangeLogPosition
+	if len(changes) > 0 {
+		lastPosition = changes[len(changes)-1].Position
+	}
+	if target.position > lastPosition {
+		return nil, fmt.Errorf("CHANGE LOG ENDS AT POSITION %d", lastPosition)
+	}
+	return replayed, nil
+}
+
+// replayChanges applies changes to restored database, caller holds the lock of database
+func replayChanges(dbName string, changes []changeEntry) error {
+	replayedTables := make(map[string]bool)
+	for _, change := range changes {
+		tablePieces := []string{dbName, change.Table}
+		lock := tableLockFor(tablePieces)
+		if err := lock.Lock(); err != nil {
+			return err
+		}
+		tables.remove(tableKey(tablePieces))
+
+		archiver := newChangeArchiver(dbName, changeEntry{
+			Operation: change.Operation,
+			Table:     change.Table,
+			Records:   change.Records,
+			Schema:    change.Schema,
+		})
+		var err error
+		switch change.Operation {
+		case changeCreateTable:
+			err = storage.CreateTable(dbName, change.Table, change.Schema, archiver)
+		case changeDeleteTable:
+			err = storage.DeleteTable(dbName, change.Table, archiver)
+		case changeAppend:
+			_, err = storage.AppendRecord(dbName, change.Table, change.Records, change.Schema, archiver)
+		case changeRewrite:
+			err = storage.RewriteRecords(dbName, change.Table, change.Records, change.Schema, archiver)
+		default:
+			err = fmt.Errorf("unknown change operation %s at position %d", change.Operation, change.Position)
+		}
+		lock.Unlock()
+		if err != nil {
+			return fmt.Errorf("change at position %d: %v", change.Position, err)
+		}
+		replayedTables[change.Table] = true
+	}
+
+	// indexes restored from backup are brought up to date with replayed changes
+	for tableName := range replayedTables {
+		tablePieces := []string{dbName, tableName}
+		lock := tableLockFor(tablePieces)
+		if err := lock.Lock(); err != nil {
+			return err
+		}
+		if _, err := storage.StatTable(dbName, tableName); err == nil {
+			if err := rebuildIndexes(tablePieces); err != nil {
+				fmt.Printf("Error while rebuilding indexes: (%v)\n", err)
+			}
+		}
+		lock.Unlock()
+	}
+	return nil
+}
+
+// restoreTable installs table staged from archive, caller holds the lock of database
+func restoreTable(dbName string, tableName string, stagingDir string) error {
+	tablePieces := []string{dbName, tableName}
+	lock := tableLockFor(tablePieces)
+	if err := lock.Lock(); err != nil {
+		return err
+	}
+	defer lock.Unlock()
+
+	schema, err := readStagedFile(stagingDir, tableName+backupSchemaSuffix)
+	if err != nil {
+		return err
+	}
+	if schema == nil {
+		schema = []byte{}
+	}
+	records, err := readStagedFile(stagingDir, tableName+backupRecordsSuffix)
+	if err != nil {
+		return err
+	}
+	indexes, err := readStagedFile(stagingDir, tableName+backupIndexSuffix)
+	if err != nil {
+		return err
+	}
+
+	tableName = strings.ToUpper(tableName)
+	archiver := newChangeArchiver(dbName, changeEntry{Operation: changeCreateTable, Table: tableName, Schema: schema})
+	if err := storage.CreateTable(dbName, tableName, schema, archiver); err != nil {
+		return err
+	}
+	tables.remove(tableKey(tablePieces))
+	archiver = newChangeArchiver(dbName, changeEntry{Operation: changeRewrite, Table: tableName, Records: records})
+	if err := storage.RewriteRecords(dbName, tableName, records, nil, archiver); err != nil {
+		return err
+	}
+	if indexes == nil {
+		return nil
+	}
+	// indexes of archive only define indexes, they are built from restored records
+	if err := storage.WriteIndexes(dbName, tableName, indexes); err != nil {
+		return err
+	}
+	return rebuildIndexes(tablePieces)
+}
+
+// dropRestoredDatabase deletes partially restored database, archiving its deletion
+func dropRestoredDatabase(dbName string) {
+	tableNames, err := storage.ListTables(dbName)
+	if err != nil {
+		fmt.Printf("Error while dropping restored database: (%v)\n", err)
+		return
+	}
+	for _, tableName := range tableNames {
+		tables.remove(tableKey([]string{dbName, tableName}))
+		archiver := newChangeArchiver(dbName, changeEntry{Operation: changeDeleteTable, Table: strings.ToUpper(tableName)})
+		if err := storage.DeleteTable(dbName, tableName, archiver); err != nil {
+			fmt.Printf("Error while dropping restored database: (%v)\n", err)
+		}
+	}
+	archiver := newChangeArchiver(dbName, changeEntry{Operation: changeDeleteDatabase})
+	if err := archiveChange(archiver); err != nil {
+		fmt.Printf("Error while logging change: (%v)\n", err)
+		return
+	}
+	if err := storage.DeleteDatabase(dbName); err != nil {
+		abortChange(archiver)
+		fmt.Printf("Error while dropping restored database: (%v)\n", err)
+	}
+}
@@ -0,0 +1,108 @@
+package engine
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// useBackups keeps backups and change logs of test in temporary directories
+func useBackups(t *testing.T) {
+	useMemoryStorage(t)
+	SetBackupDir(t.TempDir())
+	SetChangeLog(t.TempDir(), 0, 0)
+}
+
+func TestParseReplayTarget(t *testing.T) {
+	tests := []struct {
+		until  string
+		target *replayTarget
+		err    string
+	}{
+		{until: "latest", target: &replayTarget{}},
+		{until: "LATEST", target: &replayTarget{}},
+		{until: "7", target: &replayTarget{position: 7}},
+		{until: "2026-01-02T03:04:05Z", target: &replayTarget{time: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)}},
+		{until: "0", err: "INVALID --until '0', POSITIONS START AT 1"},
+		{until: "-1", err: "INVALID --until '-1', EXPECTED A POSITION, A RFC 3339 TIMESTAMP OR 'LATEST'"},
+		{until: "2026-01-02", err: "INVALID --until '2026-01-02', EXPECTED A POSITION, A RFC 3339 TIMESTAMP OR 'LATEST'"},
+	}
+	for _, test := range tests {
+		target, err := parseReplayTarget(test.until)
+		if test.err != "" {
+			if err == nil || err.Error() != test.err {
+				t.Errorf("parseReplayTarget(%q) returned error %v, expected %q", test.until, err, test.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseReplayTarget(%q) failed: %v", test.until, err)
+			continue
+		}
+		if !reflect.DeepEqual(target, test.target) {
+			t.Errorf("parseReplayTarget(%q) = %v, expected %v", test.until, target, test.target)
+		}
+	}
+}
+
+func TestBackupRestore(t *testing.T) {
+	useBackups(t)
+	mustExecute(t, "create-db d", "")
+	mustExecute(t, "create-table d:t --row-id sequence", "")
+	mustExecute(t, "write-table d:t", "name: ann\nage: 30\n")
+	mustExecute(t, "write-table d:t", "name: bob\nage: 25\n")
+	mustExecute(t, "create-index d:t age ordered", "")
+	// changes 1 to 4 are in the backup: create-db, create-table and two appends
+	if output := mustExecute(t, "backup-db d b.tgz", ""); !strings.HasSuffix(output, "CHANGE LOG POSITION: 4") {
+		t.Fatalf("backup-db = %q, expected backup at position 4", output)
+	}
+	// changes 5 and 6 are made after the backup
+	mustExecute(t, "write-table d:t", "name: cid\nage: 35\n")
+	mustExecute(t, "delete-row d:t row_id_1", "")
+
+	tests := []struct {
+		message string
+		output  string
+		err     string
+	}{
+		{message: "restore-db b.tgz", err: "DB ALREADY EXISTS"},
+		{
+			message: "restore-db b.tgz r1",
+			output:  "DB 'R1' RESTORED FROM 'b.tgz'. TABLES: 1",
+		},
+		{message: "read-table r1:t", output: "name|age\nann|30\nbob|25"},
+		{message: "filter r1:t age > 26", output: "name|age\nann|30"},
+		{
+			message: "restore-db b.tgz r2 --until 5",
+			output:  "DB 'R2' RESTORED FROM 'b.tgz'. TABLES: 1, CHANGES REPLAYED: 1, CHANGE LOG POSITION: 5",
+		},
+		{message: "read-table r2:t", output: "name|age\nann|30\nbob|25\ncid|35"},
+		{message: "filter r2:t age > 26", output: "name|age\nann|30\ncid|35"},
+		{
+			message: "restore-db b.tgz r3 --until latest",
+			output:  "DB 'R3' RESTORED FROM 'b.tgz'. TABLES: 1, CHANGES REPLAYED: 2, CHANGE LOG POSITION: 6",
+		},
+		{message: "read-table r3:t", output: "name|age\nbob|25\ncid|35"},
+		{message: "restore-db b.tgz r4 --until 0", err: "INVALID --until '0', POSITIONS START AT 1"},
+		{message: "restore-db b.tgz r4 --until 3", err: "POSITION 3 IS BEFORE BACKUP, BACKUP IS AT POSITION 4"},
+		{message: "restore-db b.tgz r4 --until 7", err: "CHANGE LOG ENDS AT POSITION 6"},
+		{message: "list-dbs", output: "D\nR1\nR2\nR3"},
+	}
+	for _, test := range tests {
+		output, err := execute(test.message, "")
+		if test.err != "" {
+			if err == nil || err.Error() != test.err {
+				t.Errorf("%s returned error %v, expected %q", test.message, err, test.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s failed: %v", test.message, err)
+			continue
+		}
+		if output != test.output {
+			t.Errorf("%s = %q, expected %q", test.message, output, test.output)
+		}
+	}
+}
@@ -0,0 +1,193 @@
+package engine
+
+import (
+	"container/list"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/sushilkm/myYamlDB/models"
+)
+
+// Parsed tables are kept in a bounded LRU cache, so that commands do not parse
+// the whole table every time. A cached table is checked against the state of
+// table records on every use, records changed outside the engine are parsed again.
+// Appends made by the engine are applied to the cached table, rewritten and
+// deleted tables are dropped from the cache.
+// A cached table is read holding the lock of its table and changed holding
+// the write lock, commands get a copy of the cached table to work on
+
+// defaultTableCacheSize number of tables cached by default
+const defaultTableCacheSize = 64
+
+type cachedTable struct {
+	key   string
+	state TableState
+	table *models.DataTable
+	// rowIDs every row-id found in table records, including row-ids of deleted rows
+	rowIDs map[string]bool
+}
+
+type tableCache struct {
+	mutex    sync.Mutex
+	capacity int
+	// recentlyUsed lists cached tables, most recently used first
+	recentlyUsed *list.List
+	elements     map[string]*list.Element
+	hits         uint64
+	misses       uint64
+}
+
+var tables = &tableCache{
+	capacity:     defaultTableCacheSize,
+	recentlyUsed: list.New(),
+	elements:     make(map[string]*list.Element),
+}
+
+func (cache *tableCache) lookup(key string, state TableState) *cachedTable {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	element, ok := cache.elements[key]
+	if !ok || element.Value.(*cachedTable).state != state {
+		cache.misses++
+		return nil
+	}
+	cache.hits++
+	cache.recentlyUsed.MoveToFront(element)
+	return element.Value.(*cachedTable)
+}
+
+func (cache *tableCache) store(entry *cachedTable) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	if element, ok := cache.elements[entry.key]; ok {
+		element.Value = entry
+		cache.recentlyUsed.MoveToFront(element)
+		return
+	}
+	cache.elements[entry.key] = cache.recentlyUsed.PushFront(entry)
+	for cache.recentlyUsed.Len() > cache.capacity {
+		oldest := cache.recentlyUsed.Back()
+		cache.recentlyUsed.Remove(oldest)
+		delete(cache.elements, oldest.Value.(*cachedTable).key)
+	}
+}
+
+// remove drops table from cache, before table is rewritten or deleted
+func (cache *tableCache) remove(key string) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	if element, ok := cache.elements[key]; ok {
+		cache.recentlyUsed.Remove(element)
+		delete(cache.elements, key)
+	}
+}
+
+// cached returns cached table without counting a hit or a miss
+func (cache *tableCache) cached(key string) *cachedTable {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	if element, ok := cache.elements[key]; ok {
+		return element.Value.(*cachedTable)
+	}
+	return nil
+}
+
+// cachedTableFile returns parsed table, from cache when it is up to date,
+// caller holds the lock of table and does not change the returned table
+func cachedTableFile(tablePieces []string) (*cachedTable, error) {
+	key := tableKey(tablePieces)
+	state, err := storage.StatTable(tablePieces[0], tablePieces[1])
+	if os.IsNotExist(err) {
+		return nil, errors.New("TABLE DOES NOT EXISTS")
+	}
+	if err != nil {
+		fmt.Printf("Error while reading table: (%v)\n", err)
+		return nil, errors.New(dbEngineError)
+	}
+	if entry := tables.lookup(key, state); entry != nil {
+		return entry, nil
+	}
+
+	tableData, err := readTableRecords(tablePieces)
+	if err != nil {
+		fmt.Printf("Error while reading table: (%v)\n", err)
+		return nil, errors.New(dbEngineError)
+	}
+	tbl, valid := models.ParseYaml(tableData)
+	if !valid {
+		return nil, errors.New("INVALID TABLE DATA")
+	}
+	rowIDs, valid := models.ParseYamlRowIDs(tableData)
+	if !valid {
+		return nil, errors.New("INVALID TABLE DATA")
+	}
+
+	entry := &cachedTable{key: key, state: state, table: tbl, rowIDs: rowIDs}
+	tables.store(entry)
+	return entry, nil
+}
+
+// appendToCachedTable applies record appended at offset to cached table,
+// a cached table not up to date with table records before the append is dropped,
+// caller holds the write lock of table
+func appendToCachedTable(tablePieces []string, recordData []byte, offset int64) {
+	key := tableKey(tablePieces)
+	entry := tables.cached(key)
+	if entry == nil {
+		return
+	}
+	state, err := storage.StatTable(tablePieces[0], tablePieces[1])
+	if err != nil || entry.state.Size != offset || entry.state.Generation != state.Generation {
+		tables.remove(key)
+		return
+	}
+	rowID, record, err := parseTableRecord(recordData)
+	if err != nil {
+		tables.remove(key)
+		return
+	}
+	appendedTable, valid := models.ParseYaml(recordData)
+	if !valid {
+		tables.remove(key)
+		return
+	}
+
+	// readers work on copies, so cached table is changed in place
+	if record == nil {
+		entry.table.DeleteRecord(rowID)
+	} else {
+		entry.table.SetRecord(rowID, *record, appendedTable.Columns)
+	}
+	entry.rowIDs[rowID] = true
+	entry.state = state
+}
+
+// SetTableCacheSize sets number of tables kept in cache, 0 disables caching,
+// least recently used tables are dropped when cache holds more tables
+func SetTableCacheSize(size int) {
+	tables.mutex.Lock()
+	defer tables.mutex.Unlock()
+
+	tables.capacity = size
+	for tables.recentlyUsed.Len() > tables.capacity {
+		oldest := tables.recentlyUsed.Back()
+		tables.recentlyUsed.Remove(oldest)
+		delete(tables.elements, oldest.Value.(*cachedTable).key)
+	}
+}
+
+// Cache stats command format is
+// cache-stats
+func (db *DBEngine) cacheStats() (string, error) {
+	tables.mutex.Lock()
+	defer tables.mutex.Unlock()
+
+	return fmt.Sprintf("tables|capacity|hits|misses\n%d|%d|%d|%d",
+		tables.recentlyUsed.Len(), tables.capacity, tables.hits, tables.misses), nil
+}
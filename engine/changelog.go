@@ -0,0 +1,482 @@
+package engine
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// List changes command format is
+// list-changes <db-name> [<after-position>]
+// Every change made to a database, creating and deleting it and its tables,
+// appending rows to tables and rewriting them, is archived to the change log of
+// the database, when a change log directory is set. Change log of a database is
+// kept in "<change-log-dir>/<DB-NAME>/" as segments "changes-<number>.log",
+// a new segment is started once the current one reaches the segment size and
+// the oldest segments beyond the number of segments to keep are deleted.
+// Every change has a position, increasing by one with every change of the database,
+// every segment starts with the position of the change before it, so positions carry on
+// across segments. restore-db replays changes made after a backup, up to a position or a time.
+// Changes are archived before they are applied, a change failing to be archived
+// is not made, a change failing to apply is followed by an abort entry and is not replayed.
+// Entries are framed as in the write-ahead log
+
+// Operations of changes
+const (
+	changeCreateDatabase = "create-db"
+	changeDeleteDatabase = "delete-db"
+	changeCreateTable    = "create-table"
+	changeDeleteTable    = "delete-table"
+	changeAppend         = "append"
+	changeRewrite        = "rewrite"
+	// changeAbort marks change with the same id as failed
+	changeAbort = "abort"
+	// changeSegmentStart first entry of a segment, holding position of the change before it
+	changeSegmentStart = "segment-start"
+)
+
+const (
+	changeSegmentPrefix = "changes-"
+	changeSegmentSuffix = ".log"
+)
+
+type changeEntry struct {
+	Position  uint64    `json:"position"`
+	Time      time.Time `json:"time"`
+	Operation string    `json:"operation"`
+	// ID identifies change of a table, as in write-ahead log of database
+	ID    string `json:"id,omitempty"`
+	Table string `json:"table,omitempty"`
+	// Records appended to table, or every record of rewritten table
+	Records []byte `json:"records,omitempty"`
+	// Schema content of table schema after the change, if the change set it
+	Schema []byte `json:"schema,omitempty"`
+}
+
+type changeLog struct {
+	mutex sync.Mutex
+	dir   string
+	// opened when position and current segment were read from disk
+	opened        bool
+	position      uint64
+	segment       *os.File
+	segmentNumber int
+	segmentSize   int64
+	// segmentChanges number of changes in current segment
+	segmentChanges int
+}
+
+var (
+	changeLogsMutex sync.Mutex
+	changeLogs      = make(map[string]*changeLog)
+
+	// change log settings, change log is disabled when changeLogDir is empty
+	changeLogDir         string
+	changeLogSegmentSize int64
+	changeLogSegments    int
+
+	// changeCounter tells apart changes made at the same time
+	changeCounter uint64
+)
+
+// SetChangeLog sets directory changes are archived to, segment size in bytes and
+// number of segments kept, 0 keeps every segment, to be called before serving clients
+func SetChangeLog(dir string, segmentSize int64, segments int) {
+	changeLogDir = dir
+	changeLogSegmentSize = segmentSize
+	changeLogSegments = segments
+}
+
+// changeLogFor returns change log of database, nil when change log is disabled
+func changeLogFor(dbName string) *changeLog {
+	if changeLogDir == "" {
+		return nil
+	}
+	dbName = strings.ToUpper(dbName)
+
+	changeLogsMutex.Lock()
+	defer changeLogsMutex.Unlock()
+
+	log, ok := changeLogs[dbName]
+	if !ok {
+		log = &changeLog{dir: filepath.Join(changeLogDir, dbName)}
+		changeLogs[dbName] = log
+	}
+	return log
+}
+
+func segmentName(number int) string {
+	return fmt.Sprintf("%s%010d%s", changeSegmentPrefix, number, changeSegmentSuffix)
+}
+
+// segmentNumbers returns numbers of segments in change log directory, oldest first
+func segmentNumbers(dir string) ([]int, error) {
+	files, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var numbers []int
+	for _, file := range files {
+		name := file.Name()
+		if !strings.HasPrefix(name, changeSegmentPrefix) || !strings.HasSuffix(name, changeSegmentSuffix) {
+			continue
+		}
+		number, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(name, changeSegmentPrefix), changeSegmentSuffix))
+		if err != nil {
+			continue
+		}
+		numbers = append(numbers, number)
+	}
+	sort.Ints(numbers)
+	return numbers, nil
+}
+
+// decodeChangeEntries decodes change log entries and returns length of data they take,
+// stopping at the first torn entry
+func decodeChangeEntries(data []byte) ([]changeEntry, int, error) {
+	bodies, length := splitLogEntries(data)
+	entries := make([]changeEntry, 0, len(bodies))
+	for _, body := range bodies {
+		var entry changeEntry
+		if err := json.Unmarshal(body, &entry); err != nil {
+			return nil, 0, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, length, nil
+}
+
+// readSegment reads changes of segment, including its start, and returns length of data
+// they take along with size of segment
+func (log *changeLog) readSegment(number int) ([]changeEntry, int, int, error) {
+	segmentData, err := ioutil.ReadFile(filepath.Join(log.dir, segmentName(number)))
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	entries, length, err := decodeChangeEntries(segmentData)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	return entries, length, len(segmentData), nil
+}
+
+// open reads position of last change and opens last segment, caller holds the log mutex
+func (log *changeLog) open() error {
+	if log.opened {
+		return nil
+	}
+	if err := os.MkdirAll(log.dir, 0700); err != nil {
+		return err
+	}
+	numbers, err := segmentNumbers(log.dir)
+	if err != nil {
+		return err
+	}
+
+	if len(numbers) > 0 {
+		log.segmentNumber = numbers[len(numbers)-1]
+		entries, length, size, err := log.readSegment(log.segmentNumber)
+		if err != nil {
+			return err
+		}
+		if log.segment, err = os.OpenFile(filepath.Join(log.dir, segmentName(log.segmentNumber)), os.O_WRONLY, 0600); err != nil {
+			return err
+		}
+		// a torn entry left behind by a crash is dropped
+		if length != size {
+			if err := log.segment.Truncate(int64(length)); err != nil {
+				return err
+			}
+		}
+		log.segmentSize = int64(length)
+		for _, entry := range entries {
+			if entry.Operation != changeSegmentStart {
+				log.segmentChanges++
+			}
+		}
+
+		// a segment started right before a crash can be empty, even of its start,
+		// position is then read from the segments before it
+		for i := len(numbers) - 1; len(entries) == 0 && i > 0; i-- {
+			if entries, _, _, err = log.readSegment(numbers[i-1]); err != nil {
+				return err
+			}
+		}
+		if len(entries) > 0 {
+			log.position = entries[len(entries)-1].Position
+		}
+	}
+	log.opened = true
+	return nil
+}
+
+// write writes entry at the end of current segment and flushes it, caller holds the log mutex
+func (log *changeLog) write(entry changeEntry) error {
+	encodedEntry, err := frameLogEntry(entry)
+	if err != nil {
+		return err
+	}
+	if _, err := log.segment.WriteAt(encodedEntry, log.segmentSize); err != nil {
+		return err
+	}
+	if err := log.segment.Sync(); err != nil {
+		return err
+	}
+	log.segmentSize += int64(len(encodedEntry))
+	return nil
+}
+
+// rotate starts a new segment and deletes segments beyond the number kept,
+// caller holds the log mutex
+func (log *changeLog) rotate() error {
+	if log.segment != nil {
+		err := log.segment.Close()
+		log.segment = nil
+		log.segmentNumber++
+		if err != nil {
+			return err
+		}
+	}
+	segment, err := os.OpenFile(filepath.Join(log.dir, segmentName(log.segmentNumber)), os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	log.segment = segment
+	log.segmentSize = 0
+	log.segmentChanges = 0
+	if err := log.write(changeEntry{Position: log.position, Time: time.Now().UTC(), Operation: changeSegmentStart}); err != nil {
+		return err
+	}
+	if err := syncDir(log.dir); err != nil {
+		return err
+	}
+
+	// older segments are only deleted once the new one holds the position
+	if changeLogSegments <= 0 {
+		return nil
+	}
+	numbers, err := segmentNumbers(log.dir)
+	if err != nil {
+		return err
+	}
+	for len(numbers) > changeLogSegments {
+		if err := os.Remove(filepath.Join(log.dir, segmentName(numbers[0]))); err != nil {
+			return err
+		}
+		numbers = numbers[1:]
+	}
+	return nil
+}
+
+// append archives change, setting its position and time
+func (log *changeLog) append(entry changeEntry) error {
+	log.mutex.Lock()
+	defer log.mutex.Unlock()
+
+	if err := log.open(); err != nil {
+		return err
+	}
+	entry.Position = log.position + 1
+	entry.Time = time.Now().UTC()
+	encodedEntry, err := frameLogEntry(entry)
+	if err != nil {
+		return err
+	}
+
+	if log.segment == nil || (log.segmentChanges > 0 && log.segmentSize+int64(len(encodedEntry)) > changeLogSegmentSize) {
+		if err := log.rotate(); err != nil {
+			return err
+		}
+	}
+	if err := log.write(entry); err != nil {
+		return err
+	}
+	log.segmentChanges++
+	log.position = entry.Position
+	return nil
+}
+
+// lastPosition returns position of last change
+func (log *changeLog) lastPosition() (uint64, error) {
+	log.mutex.Lock()
+	defer log.mutex.Unlock()
+
+	if err := log.open(); err != nil {
+		return 0, err
+	}
+	return log.position, nil
+}
+
+// changesAfter reads changes archived after position, oldest first
+func (log *changeLog) changesAfter(position uint64) ([]changeEntry, error) {
+	log.mutex.Lock()
+	defer log.mutex.Unlock()
+
+	numbers, err := segmentNumbers(log.dir)
+	if err != nil {
+		return nil, err
+	}
+	var changes []changeEntry
+	for _, number := range numbers {
+		segmentData, err := ioutil.ReadFile(filepath.Join(log.dir, segmentName(number)))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		entries, _, err := decodeChangeEntries(segmentData)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			if entry.Position > position && entry.Operation != changeSegmentStart {
+				changes = append(changes, entry)
+			}
+		}
+	}
+	return changes, nil
+}
+
+// changeStatus reads if change identified by id was archived, and if it was aborted
+func (log *changeLog) changeStatus(id string) (bool, bool, error) {
+	changes, err := log.changesAfter(0)
+	if err != nil {
+		return false, false, err
+	}
+	var archived, aborted bool
+	for _, change := range changes {
+		if change.ID != id {
+			continue
+		}
+		if change.Operation == changeAbort {
+			aborted = true
+		} else {
+			archived = true
+		}
+	}
+	return archived, aborted, nil
+}
+
+// changeArchiver archives a change of a database or of a table made through storage
+type changeArchiver struct {
+	log   *changeLog
+	entry changeEntry
+}
+
+// newChangeArchiver returns archiver of change of database, nil when change log is disabled
+func newChangeArchiver(dbName string, entry changeEntry) ChangeArchiver {
+	log := changeLogFor(dbName)
+	if log == nil {
+		return nil
+	}
+	entry.ID = fmt.Sprintf("%d-%d", time.Now().UnixNano(), atomic.AddUint64(&changeCounter, 1))
+	return &changeArchiver{log: log, entry: entry}
+}
+
+func (archiver *changeArchiver) ChangeID() string {
+	return archiver.entry.ID
+}
+
+func (archiver *changeArchiver) Archive() error {
+	return archiver.log.append(archiver.entry)
+}
+
+func (archiver *changeArchiver) Abort() error {
+	return archiver.log.append(changeEntry{Operation: changeAbort, ID: archiver.entry.ID, Table: archiver.entry.Table})
+}
+
+// reconcileChange brings change log of database in line with a change applied again from
+// its write-ahead log on recovery, a change not archived before the crash is archived,
+// false is returned for a change archived as aborted, which is not to be applied
+func reconcileChange(dbName string, entry walEntry) (bool, error) {
+	log := changeLogFor(dbName)
+	if log == nil || entry.Change == "" {
+		return true, nil
+	}
+	archived, aborted, err := log.changeStatus(entry.Change)
+	if err != nil {
+		return false, err
+	}
+	if aborted {
+		return false, nil
+	}
+	if archived {
+		return true, nil
+	}
+
+	change := changeEntry{
+		ID:      entry.Change,
+		Table:   strings.TrimSuffix(entry.Table, tableFileSuffix),
+		Records: entry.Record,
+		Schema:  entry.Schema,
+	}
+	switch entry.Operation {
+	case "":
+		change.Operation = changeAppend
+	case walCreateTable:
+		change.Operation = changeCreateTable
+	case walDeleteTable:
+		change.Operation = changeDeleteTable
+	case walRewrite:
+		change.Operation = changeRewrite
+	default:
+		return true, nil
+	}
+	return true, log.append(change)
+}
+
+// changeLogPosition returns position of last change of database, 0 when change log is disabled
+func changeLogPosition(dbName string) (uint64, error) {
+	log := changeLogFor(dbName)
+	if log == nil {
+		return 0, nil
+	}
+	return log.lastPosition()
+}
+
+func (db *DBEngine) listChanges() (string, error) {
+	if len(db.cmdArgs) < 1 || len(db.cmdArgs) > 2 {
+		return "", errors.New("INVALID NUMBER OF ARGUMENTS")
+	}
+	log := changeLogFor(db.cmdArgs[0])
+	if log == nil {
+		return "", errors.New("CHANGE LOG IS DISABLED")
+	}
+	var after uint64
+	if len(db.cmdArgs) == 2 {
+		position, err := strconv.ParseUint(db.cmdArgs[1], 10, 64)
+		if err != nil {
+			return "", errors.New("INVALID POSITION, SHOULD BE A NUMBER")
+		}
+		after = position
+	}
+
+	changes, err := log.changesAfter(after)
+	if err != nil {
+		fmt.Printf("Error while reading change log: (%v)\n", err)
+		return "", errors.New(dbEngineError)
+	}
+	if len(changes) == 0 {
+		return "NO CHANGES EXIST", nil
+	}
+	changeList := "position|time|operation|table|bytes"
+	for _, change := range changes {
+		changeList += fmt.Sprintf("\n%d|%s|%s|%s|%d", change.Position, change.Time.Format(time.RFC3339Nano),
+			change.Operation, change.Table, len(change.Records))
+	}
+	return changeList, nil
+}
@@ -0,0 +1,135 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/sushilkm/myYamlDB/models"
+)
+
+// Compact table command format is
+// compact-table <db-name>:<table-name>
+// Rows are only ever appended to table records, so updated rows, deleted rows and
+// their tombstones pile up in them. Compaction rewrites table records keeping only live rows.
+// Table is read and compacted holding a read lock, so readers are not blocked,
+// the write lock is taken only to move the compacted table in place,
+// rows appended meanwhile are carried over to the compacted table
+
+// compactTableFile compacts table and returns size of its records before and after compaction
+func compactTableFile(tablePieces []string) (int64, int64, error) {
+	lock := tableLockFor(tablePieces)
+
+	if err := lock.RLock(); err != nil {
+		return 0, 0, err
+	}
+	state, err := storage.StatTable(tablePieces[0], tablePieces[1])
+	if os.IsNotExist(err) {
+		lock.RUnlock()
+		return 0, 0, errors.New("TABLE DOES NOT EXISTS")
+	}
+	if err != nil {
+		lock.RUnlock()
+		fmt.Printf("Error while compacting table: (%v)\n", err)
+		return 0, 0, errors.New(dbEngineError)
+	}
+	tableData, err := readTableRecords(tablePieces)
+	lock.RUnlock()
+	if err != nil {
+		fmt.Printf("Error while compacting table: (%v)\n", err)
+		return 0, 0, errors.New(dbEngineError)
+	}
+
+	tbl, valid := models.ParseYaml(tableData)
+	if !valid {
+		return 0, 0, errors.New("INVALID TABLE DATA")
+	}
+	compactedData, err := tbl.ToYaml()
+	if err != nil {
+		fmt.Printf("Error while compacting table: (%v)\n", err)
+		return 0, 0, errors.New(dbEngineError)
+	}
+	if int64(len(compactedData)) >= state.Size {
+		// nothing to reclaim
+		return state.Size, state.Size, nil
+	}
+
+	if err := lock.Lock(); err != nil {
+		return 0, 0, err
+	}
+	defer lock.Unlock()
+
+	currentState, err := storage.StatTable(tablePieces[0], tablePieces[1])
+	if err != nil || currentState.Generation != state.Generation || currentState.Size < state.Size {
+		// table was deleted or rewritten meanwhile
+		return 0, 0, errors.New("TABLE CHANGED WHILE COMPACTING, TRY AGAIN")
+	}
+	if currentState.Size > state.Size {
+		appendedData, err := storage.ReadRecordsAt(tablePieces[0], tablePieces[1], state.Size, -1)
+		if err != nil {
+			fmt.Printf("Error while compacting table: (%v)\n", err)
+			return 0, 0, errors.New(dbEngineError)
+		}
+		compactedData = append(compactedData, appendedData...)
+	}
+	tables.remove(tableKey(tablePieces))
+	if err := storage.RewriteRecords(tablePieces[0], tablePieces[1], compactedData, nil, nil); err != nil {
+		fmt.Printf("Error while compacting table: (%v)\n", err)
+		return 0, 0, errors.New(dbEngineError)
+	}
+	if err := rebuildIndexes(tablePieces); err != nil {
+		fmt.Printf("Error while rebuilding indexes: (%v)\n", err)
+	}
+	return currentState.Size, int64(len(compactedData)), nil
+}
+
+func (db *DBEngine) compactTable() (string, error) {
+	tablePieces, err := db.parseTableName()
+	if err != nil {
+		return "", err
+	}
+
+	sizeBefore, sizeAfter, err := compactTableFile(tablePieces)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("TABLE '%s:%s' COMPACTED. BYTES RECLAIMED: %d", tablePieces[0], tablePieces[1], sizeBefore-sizeAfter), nil
+}
+
+// StartCompactor compacts every table of every database once every interval,
+// in the background, for as long as the server runs
+func StartCompactor(interval time.Duration) {
+	go func() {
+		for range time.Tick(interval) {
+			compactDatabases()
+		}
+	}()
+}
+
+func compactDatabases() {
+	databases, err := storage.ListDatabases()
+	if err != nil {
+		fmt.Printf("Error while compacting databases: (%v)\n", err)
+		return
+	}
+
+	for _, database := range databases {
+		tableNames, err := storage.ListTables(database)
+		if err != nil {
+			fmt.Printf("Error while compacting databases: (%v)\n", err)
+			continue
+		}
+		for _, tableName := range tableNames {
+			tablePieces := []string{database, tableName}
+			sizeBefore, sizeAfter, err := compactTableFile(tablePieces)
+			if err != nil {
+				fmt.Printf("Error while compacting table %s:%s: (%v)\n", tablePieces[0], tablePieces[1], err)
+				continue
+			}
+			if sizeBefore > sizeAfter {
+				fmt.Printf("Compacted table %s:%s, %d bytes reclaimed\n", tablePieces[0], tablePieces[1], sizeBefore-sizeAfter)
+			}
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package engine
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCompactTable(t *testing.T) {
+	useMemoryStorage(t)
+	mustExecute(t, "create-db d", "")
+	mustExecute(t, "create-table d:t --row-id sequence", "")
+	mustExecute(t, "create-table d:empty", "")
+	for _, payload := range []string{"name: ann\nage: 30\n", "name: bob\nage: 25\n", "name: cid\nage: 35\n"} {
+		mustExecute(t, "write-table d:t", payload)
+	}
+	mustExecute(t, "create-index d:t age ordered", "")
+	mustExecute(t, "update-row d:t row_id_1", "name: ann\nage: 31\n")
+	mustExecute(t, "update-row d:t row_id_1", "name: ann\nage: 32\n")
+	mustExecute(t, "delete-row d:t row_id_2", "")
+
+	tests := []struct {
+		message string
+		payload string
+		output  string
+		err     string
+		// reclaimed is set when the command reclaims bytes, instead of the output
+		reclaimed bool
+		// number of records kept in table records after the command, when checked
+		records int
+	}{
+		{message: "read-table d:t", output: "name|age\nann|32\ncid|35", records: 6},
+		{message: "compact-table d:t", reclaimed: true, records: 2},
+		{message: "read-table d:t", output: "name|age\nann|32\ncid|35"},
+		{message: "filter d:t age > 31", output: "name|age\nann|32\ncid|35"},
+		{message: "filter d:t age = 30", output: "name|age"},
+		{message: "sort d:t age desc", output: "name|age\ncid|35\nann|32"},
+		// nothing left to reclaim
+		{message: "compact-table d:t", output: "TABLE 'd:t' COMPACTED. BYTES RECLAIMED: 0", records: 2},
+		{message: "compact-table d:empty", output: "TABLE 'd:empty' COMPACTED. BYTES RECLAIMED: 0"},
+		// row-ids of rows dropped by compaction are not given out again
+		{message: "write-table d:t", payload: "name: dan\nage: 20\n", output: "TABLE 'd:t' WRITTEN. ROW-ID: row_id_4", records: 3},
+		{message: "filter d:t age < 30", output: "name|age\ndan|20"},
+		{message: "compact-table d:missing", err: "TABLE DOES NOT EXISTS"},
+		{message: "compact-table d", err: "INVALID TABLE-NAME"},
+	}
+	for _, test := range tests {
+		output, err := execute(test.message, test.payload)
+		if test.err != "" {
+			if err == nil || err.Error() != test.err {
+				t.Errorf("%s returned error %v, expected %q", test.message, err, test.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s failed: %v", test.message, err)
+			continue
+		}
+		if test.reclaimed {
+			if !strings.HasPrefix(output, "TABLE 'd:t' COMPACTED. BYTES RECLAIMED: ") || strings.HasSuffix(output, " 0") {
+				t.Errorf("%s = %q, expected bytes reclaimed", test.message, output)
+			}
+		} else if output != test.output {
+			t.Errorf("%s = %q, expected %q", test.message, output, test.output)
+		}
+		if test.records == 0 {
+			continue
+		}
+		records, err := storage.ReadRecords("d", "t")
+		if err != nil {
+			t.Errorf("%s: ReadRecords failed: %v", test.message, err)
+			continue
+		}
+		if count := strings.Count(string(records), "row_id_"); count != test.records {
+			t.Errorf("%s left %d records in table, expected %d", test.message, count, test.records)
+		}
+	}
+}
@@ -0,0 +1,32 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/sushilkm/myYamlDB/common"
+)
+
+// Show config command format is
+// show-config
+// Lists every server setting along with its value and where it was taken from
+
+// serverConfig settings server was started with
+var serverConfig *common.Config
+
+// SetConfig sets settings server was started with, to be called before serving clients
+func SetConfig(config *common.Config) {
+	serverConfig = config
+}
+
+func (db *DBEngine) showConfig() (string, error) {
+	if serverConfig == nil {
+		return "", errors.New("NO CONFIG LOADED")
+	}
+
+	configList := "setting|value|source"
+	for _, name := range common.ConfigSettingNames() {
+		configList += fmt.Sprintf("\n%s|%s|%s", name, serverConfig.Values[name], serverConfig.Sources[name])
+	}
+	return configList, nil
+}
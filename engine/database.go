@@ -3,40 +3,27 @@ package engine
 import (
 	"errors"
 	"fmt"
-	"io/ioutil"
-	"os"
-	"path/filepath"
 	"strings"
-
-	"github.com/sushilkm/myYamlDB/common"
 )
 
-func (db *DBEngine) initializeDatabase() error {
-	_, err := os.Stat(common.DBLocation)
-	if os.IsNotExist(err) {
-		return os.Mkdir(common.DBLocation, 0700)
-	}
-	return nil
-}
-
 func (db *DBEngine) checkDatabase() (string, error) {
 	if len(db.cmdArgs) != 1 {
 		return "", errors.New("INVALID DB-NAME")
 	}
 
-	dbPath := filepath.Join(common.DBLocation, strings.ToUpper(db.cmdArgs[0])) + dbFileSuffix
-	if _, err := os.Stat(dbPath); os.IsNotExist(err) {
+	exists, err := storage.DatabaseExists(db.cmdArgs[0])
+	if err != nil {
+		fmt.Printf("Error while checking database: (%v)\n", err)
+		return "", errors.New(dbEngineError)
+	}
+	if !exists {
 		return "", errors.New("DB DOES NOT EXISTS")
 	}
 
 	return strings.ToUpper(db.cmdArgs[0]), nil
 }
 func (db *DBEngine) listDatabases() (string, error) {
-	if _, err := os.Stat(common.DBLocation); os.IsNotExist(err) {
-		return "NO DATABASES EXIST", nil
-	}
-
-	databases, err := ioutil.ReadDir(common.DBLocation)
+	databases, err := storage.ListDatabases()
 	if err != nil {
 		fmt.Printf("Error while listing databases: (%v)\n", err)
 		return "", errors.New(dbEngineError)
@@ -45,18 +32,8 @@ func (db *DBEngine) listDatabases() (string, error) {
 	if len(databases) == 0 {
 		return "NO DATABASES EXIST", nil
 	}
-	var dbList string
-	for _, database := range databases {
-		if !strings.HasSuffix(database.Name(), dbFileSuffix) {
-			continue
-		}
-		if dbList != "" {
-			dbList += "\n"
-		}
-		dbList += strings.TrimSuffix(database.Name(), dbFileSuffix)
-	}
 
-	return strings.ToUpper(dbList), nil
+	return strings.ToUpper(strings.Join(databases, "\n")), nil
 }
 
 func (db *DBEngine) createDatabase() (string, error) {
@@ -64,16 +41,28 @@ func (db *DBEngine) createDatabase() (string, error) {
 		return "", errors.New("INVALID DB-NAME, CANNOT CREATE DATABASE")
 	}
 
-	if err := db.initializeDatabase(); err != nil {
+	lock := lockFor(databaseKey(db.cmdArgs[0]))
+	if err := lock.Lock(); err != nil {
 		return "", err
 	}
+	defer lock.Unlock()
 
-	dbPath := filepath.Join(common.DBLocation, strings.ToUpper(db.cmdArgs[0])) + dbFileSuffix
-	if _, err := os.Stat(dbPath); !os.IsNotExist(err) {
+	exists, err := storage.DatabaseExists(db.cmdArgs[0])
+	if err != nil {
+		fmt.Printf("Error while creating database: (%v)\n", err)
+		return "", errors.New(dbEngineError)
+	}
+	if exists {
 		return "", errors.New("DB ALREADY EXISTS")
 	}
 
-	if err := os.Mkdir(dbPath, 0700); err != nil {
+	archiver := newChangeArchiver(db.cmdArgs[0], changeEntry{Operation: changeCreateDatabase})
+	if err := archiveChange(archiver); err != nil {
+		fmt.Printf("Error while logging change: (%v)\n", err)
+		return "", errors.New(dbEngineError)
+	}
+	if err := storage.CreateDatabase(db.cmdArgs[0]); err != nil {
+		abortChange(archiver)
 		return "", err
 	}
 
@@ -85,11 +74,21 @@ func (db *DBEngine) deleteDatabase() (string, error) {
 		return "", errors.New("INVALID DB-NAME, CANNOT DELETE DATABASE")
 	}
 
-	dbPath := filepath.Join(common.DBLocation, strings.ToUpper(db.cmdArgs[0])) + dbFileSuffix
-	if _, err := os.Stat(dbPath); os.IsNotExist(err) {
+	lock := lockFor(databaseKey(db.cmdArgs[0]))
+	if err := lock.Lock(); err != nil {
+		return "", err
+	}
+	defer lock.Unlock()
+
+	exists, err := storage.DatabaseExists(db.cmdArgs[0])
+	if err != nil {
+		fmt.Printf("Error while deleting database: (%v)\n", err)
+		return "", errors.New(dbEngineError)
+	}
+	if !exists {
 		return "", errors.New("DB DOES NOT EXISTS")
 	}
-	tables, err := ioutil.ReadDir(dbPath)
+	tables, err := storage.ListTables(db.cmdArgs[0])
 	if err != nil {
 		fmt.Printf("Error while deleting database: (%v)\n", err)
 		return "", errors.New(dbEngineError)
@@ -97,7 +96,13 @@ func (db *DBEngine) deleteDatabase() (string, error) {
 	if len(tables) > 0 {
 		return "", errors.New("CANNOT DELETE DATABASE, IT HAS TABLES")
 	}
-	if err := os.Remove(dbPath); err != nil {
+	archiver := newChangeArchiver(db.cmdArgs[0], changeEntry{Operation: changeDeleteDatabase})
+	if err := archiveChange(archiver); err != nil {
+		fmt.Printf("Error while logging change: (%v)\n", err)
+		return "", errors.New(dbEngineError)
+	}
+	if err := storage.DeleteDatabase(db.cmdArgs[0]); err != nil {
+		abortChange(archiver)
 		return "", err
 	}
 
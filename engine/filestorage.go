@@ -0,0 +1,310 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// File storage keeps every database as a directory "<DB-NAME>.db" under its location,
+// every table as a yaml file "<TABLE-NAME>.tbl" in its database directory,
+// along with "<TABLE-NAME>.schema" and "<TABLE-NAME>.idx" files.
+// Changes to tables go through the write-ahead log of the database, files are rewritten
+// by writing them aside and renaming them in place.
+// A server owns its location by holding an exclusive lock on "LOCK" file in it,
+// tables are locked through "<TABLE-NAME>.lock" files in their database directory
+
+const (
+	dbFileSuffix     = ".db"
+	tableFileSuffix  = ".tbl"
+	schemaFileSuffix = ".schema"
+	indexFileSuffix  = ".idx"
+	walFileName      = "JOURNAL.wal"
+	lockFileSuffix   = ".lock"
+	locationLockName = "LOCK"
+)
+
+// errFileLocked returned when a file is locked by another process
+var errFileLocked = errors.New("file is locked by another process")
+
+type fileStorage struct {
+	location string
+	// locationLock lock file held open by the server owning location
+	locationLock *os.File
+
+	mutex sync.Mutex
+	// generations number of rewrites of table files
+	generations map[string]uint64
+}
+
+// NewFileStorage returns storage keeping databases as directories under location
+func NewFileStorage(location string) Storage {
+	return &fileStorage{location: location, generations: make(map[string]uint64)}
+}
+
+func (fs *fileStorage) dbPath(dbName string) string {
+	return filepath.Join(fs.location, strings.ToUpper(dbName)+dbFileSuffix)
+}
+
+func (fs *fileStorage) tablePath(dbName string, tableName string, suffix string) string {
+	return filepath.Join(fs.dbPath(dbName), strings.ToUpper(tableName)+suffix)
+}
+
+func (fs *fileStorage) rewritten(tableFileName string) {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+
+	fs.generations[tableFileName]++
+}
+
+// Open locks location for this process, the lock is held until the process exits
+func (fs *fileStorage) Open() error {
+	if err := os.MkdirAll(fs.location, 0700); err != nil {
+		return err
+	}
+	lockFileName := filepath.Join(fs.location, locationLockName)
+	f, err := os.OpenFile(lockFileName, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	if err := lockFile(f, true, false); err != nil {
+		owner, _ := ioutil.ReadFile(lockFileName)
+		f.Close()
+		if err == errFileLocked {
+			return fmt.Errorf("data directory %s is used by another server process (pid %s)",
+				fs.location, strings.TrimSpace(string(owner)))
+		}
+		return err
+	}
+	// record owner of location for the error reported to other processes
+	if err := f.Truncate(0); err != nil {
+		return err
+	}
+	if _, err := f.WriteAt([]byte(fmt.Sprintf("%d\n", os.Getpid())), 0); err != nil {
+		return err
+	}
+	fs.locationLock = f
+	return nil
+}
+
+// Recover applies appends left in write-ahead logs of databases by a crash
+func (fs *fileStorage) Recover() error {
+	databases, err := ioutil.ReadDir(fs.location)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, database := range databases {
+		if !database.IsDir() || !strings.HasSuffix(database.Name(), dbFileSuffix) {
+			continue
+		}
+		if err := recoverDatabase(filepath.Join(fs.location, database.Name())); err != nil {
+			return fmt.Errorf("recovering database %s: %v", database.Name(), err)
+		}
+	}
+	return nil
+}
+
+func (fs *fileStorage) ListDatabases() ([]string, error) {
+	databases, err := ioutil.ReadDir(fs.location)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var dbNames []string
+	for _, database := range databases {
+		if database.IsDir() && strings.HasSuffix(database.Name(), dbFileSuffix) {
+			dbNames = append(dbNames, strings.TrimSuffix(database.Name(), dbFileSuffix))
+		}
+	}
+	return dbNames, nil
+}
+
+func (fs *fileStorage) DatabaseExists(dbName string) (bool, error) {
+	_, err := os.Stat(fs.dbPath(dbName))
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (fs *fileStorage) CreateDatabase(dbName string) error {
+	if err := os.MkdirAll(fs.location, 0700); err != nil {
+		return err
+	}
+	return os.Mkdir(fs.dbPath(dbName), 0700)
+}
+
+func (fs *fileStorage) DeleteDatabase(dbName string) error {
+	dbPath := fs.dbPath(dbName)
+	closeWAL(dbPath)
+	if err := os.Remove(filepath.Join(dbPath, walFileName)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return os.Remove(dbPath)
+}
+
+func (fs *fileStorage) ListTables(dbName string) ([]string, error) {
+	tables, err := ioutil.ReadDir(fs.dbPath(dbName))
+	if err != nil {
+		return nil, err
+	}
+
+	var tableNames []string
+	for _, table := range tables {
+		if strings.HasSuffix(table.Name(), tableFileSuffix) {
+			tableNames = append(tableNames, strings.TrimSuffix(table.Name(), tableFileSuffix))
+		}
+	}
+	return tableNames, nil
+}
+
+func (fs *fileStorage) CreateTable(dbName string, tableName string, schema []byte, archiver ChangeArchiver) error {
+	if _, err := os.Stat(fs.dbPath(dbName)); err != nil {
+		return err
+	}
+	tableFileName := fs.tablePath(dbName, tableName, tableFileSuffix)
+	if _, err := os.Stat(tableFileName); err == nil {
+		return errors.New("table file already exists")
+	}
+	fs.rewritten(tableFileName)
+	return logAndApply(fs.dbPath(dbName), walEntry{
+		Operation: walCreateTable,
+		Table:     filepath.Base(tableFileName),
+		Schema:    schema,
+	}, archiver)
+}
+
+func (fs *fileStorage) DeleteTable(dbName string, tableName string, archiver ChangeArchiver) error {
+	tableFileName := fs.tablePath(dbName, tableName, tableFileSuffix)
+	if _, err := os.Stat(tableFileName); err != nil {
+		return err
+	}
+	fs.rewritten(tableFileName)
+	return logAndApply(fs.dbPath(dbName), walEntry{Operation: walDeleteTable, Table: filepath.Base(tableFileName)}, archiver)
+}
+
+func (fs *fileStorage) LockTable(dbName string, tableName string, exclusive bool) (func(), error) {
+	if _, err := os.Stat(fs.tablePath(dbName, tableName, tableFileSuffix)); os.IsNotExist(err) {
+		return func() {}, nil
+	}
+	f, err := os.OpenFile(fs.tablePath(dbName, tableName, lockFileSuffix), os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+	if err := lockFile(f, exclusive, true); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return func() {
+		unlockFile(f)
+		f.Close()
+	}, nil
+}
+
+func (fs *fileStorage) StatTable(dbName string, tableName string) (TableState, error) {
+	tableFileName := fs.tablePath(dbName, tableName, tableFileSuffix)
+	tableFileInfo, err := os.Stat(tableFileName)
+	if err != nil {
+		return TableState{}, err
+	}
+
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+
+	return TableState{
+		Size:       tableFileInfo.Size(),
+		Version:    fmt.Sprint(tableFileInfo.ModTime().UnixNano()),
+		Generation: fs.generations[tableFileName],
+	}, nil
+}
+
+func (fs *fileStorage) ReadRecords(dbName string, tableName string) ([]byte, error) {
+	return ioutil.ReadFile(fs.tablePath(dbName, tableName, tableFileSuffix))
+}
+
+func (fs *fileStorage) ReadRecordsAt(dbName string, tableName string, offset int64, length int64) ([]byte, error) {
+	f, err := os.Open(fs.tablePath(dbName, tableName, tableFileSuffix))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	if length < 0 {
+		if _, err := f.Seek(offset, 0); err != nil {
+			return nil, err
+		}
+		return ioutil.ReadAll(f)
+	}
+	records := make([]byte, length)
+	if _, err := f.ReadAt(records, offset); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+func (fs *fileStorage) AppendRecord(dbName string, tableName string, record []byte, schema []byte, archiver ChangeArchiver) (int64, error) {
+	return logAndAppend(
+		fs.dbPath(dbName),
+		fs.tablePath(dbName, tableName, tableFileSuffix),
+		fs.tablePath(dbName, tableName, schemaFileSuffix),
+		record, schema, archiver,
+	)
+}
+
+func (fs *fileStorage) RewriteRecords(dbName string, tableName string, records []byte, schema []byte, archiver ChangeArchiver) error {
+	tableFileName := fs.tablePath(dbName, tableName, tableFileSuffix)
+	fs.rewritten(tableFileName)
+	return logAndApply(fs.dbPath(dbName), walEntry{
+		Operation: walRewrite,
+		Table:     filepath.Base(tableFileName),
+		Record:    records,
+		Schema:    schema,
+	}, archiver)
+}
+
+// readOptionalFile reads file, nil when it does not exist
+func readOptionalFile(fileName string) ([]byte, error) {
+	data, err := ioutil.ReadFile(fileName)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	return data, err
+}
+
+func (fs *fileStorage) ReadSchema(dbName string, tableName string) ([]byte, error) {
+	return readOptionalFile(fs.tablePath(dbName, tableName, schemaFileSuffix))
+}
+
+func (fs *fileStorage) WriteSchema(dbName string, tableName string, schema []byte) error {
+	return logAndApply(fs.dbPath(dbName), walEntry{
+		Operation: walSchema,
+		Table:     filepath.Base(fs.tablePath(dbName, tableName, tableFileSuffix)),
+		Schema:    schema,
+	}, nil)
+}
+
+func (fs *fileStorage) ReadIndexes(dbName string, tableName string) ([]byte, error) {
+	return readOptionalFile(fs.tablePath(dbName, tableName, indexFileSuffix))
+}
+
+func (fs *fileStorage) WriteIndexes(dbName string, tableName string, indexes []byte) error {
+	indexFileName := fs.tablePath(dbName, tableName, indexFileSuffix)
+	if indexes == nil {
+		if err := os.Remove(indexFileName); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	return rewriteFile(indexFileName, indexes)
+}
@@ -0,0 +1,262 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/sushilkm/myYamlDB/models"
+)
+
+// Filter command format is
+// filter <db-name>:<table-name> <column> <operator> <value> [and|or <column> <operator> <value> ...]
+// 'and' binds tighter than 'or', so "a = 1 or b = 2 and c = 3" matches a = 1 or (b = 2 and c = 3)
+// column can address nested data, e.g. "address.city" or "tags[0]",
+// 'contains' on a list column matches rows having the value as a list item
+
+var filterOperators = map[string]bool{
+	"=":        true,
+	"!=":       true,
+	"<":        true,
+	"<=":       true,
+	">":        true,
+	">=":       true,
+	"CONTAINS": true,
+	"PREFIX":   true,
+}
+
+type filterCondition struct {
+	column   string
+	operator string
+	value    string
+}
+
+// filterExpression is a list of 'or' groups, each group is a list of 'and' conditions
+type filterExpression [][]filterCondition
+
+func parseFilterExpression(args []string) (filterExpression, error) {
+	if len(args) == 0 {
+		return nil, errors.New("NO FILTER CONDITION PROVIDED")
+	}
+
+	var expression filterExpression
+	var group []filterCondition
+	for i := 0; i < len(args); {
+		if len(args)-i < 3 {
+			return nil, errors.New("INCOMPLETE FILTER CONDITION, EXPECTED <COLUMN> <OPERATOR> <VALUE>")
+		}
+		if err := models.ValidateColumnPath(args[i]); err != nil {
+			return nil, fmt.Errorf("INVALID FILTER COLUMN '%s'", args[i])
+		}
+		operator := strings.ToUpper(args[i+1])
+		if !filterOperators[operator] {
+			return nil, fmt.Errorf("INVALID FILTER OPERATOR '%s'", args[i+1])
+		}
+		group = append(group, filterCondition{column: args[i], operator: operator, value: args[i+2]})
+		i += 3
+
+		if i == len(args) {
+			break
+		}
+		switch strings.ToUpper(args[i]) {
+		case "AND":
+		case "OR":
+			expression = append(expression, group)
+			group = nil
+		default:
+			return nil, fmt.Errorf("INVALID FILTER CONJUNCTION '%s', EXPECTED 'AND' OR 'OR'", args[i])
+		}
+		i++
+		if i == len(args) {
+			return nil, errors.New("INCOMPLETE FILTER CONDITION, EXPECTED <COLUMN> <OPERATOR> <VALUE>")
+		}
+	}
+	return append(expression, group), nil
+}
+
+func (condition filterCondition) matches(record models.DataRecord) (bool, error) {
+	column, ok := record.Lookup(condition.column)
+	if !ok {
+		return false, nil
+	}
+
+	switch condition.operator {
+	case "CONTAINS":
+		if list, ok := column.ColumnData.([]interface{}); ok {
+			for _, item := range list {
+				if (models.DataColumn{ColumnData: item}).String() == condition.value {
+					return true, nil
+				}
+			}
+			return false, nil
+		}
+		return strings.Contains(column.String(), condition.value), nil
+	case "PREFIX":
+		return strings.HasPrefix(column.String(), condition.value), nil
+	}
+
+	if column.ColumnData == nil {
+		return false, nil
+	}
+	value, err := models.ParseData(column.ColumnData, condition.value)
+	if err != nil {
+		return false, fmt.Errorf("INVALID FILTER VALUE '%s' FOR COLUMN '%s'", condition.value, condition.column)
+	}
+	result, err := models.CompareData(column.ColumnData, value)
+	if err != nil {
+		return false, fmt.Errorf("INVALID FILTER VALUE '%s' FOR COLUMN '%s'", condition.value, condition.column)
+	}
+
+	switch condition.operator {
+	case "=":
+		return result == 0, nil
+	case "!=":
+		return result != 0, nil
+	case "<":
+		return result < 0, nil
+	case "<=":
+		return result <= 0, nil
+	case ">":
+		return result > 0, nil
+	default:
+		return result >= 0, nil
+	}
+}
+
+func (expression filterExpression) matches(record models.DataRecord) (bool, error) {
+	for _, group := range expression {
+		groupMatches := true
+		for _, condition := range group {
+			matched, err := condition.matches(record)
+			if err != nil {
+				return false, err
+			}
+			if !matched {
+				groupMatches = false
+				break
+			}
+		}
+		if groupMatches {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// planFilterIndexes returns for every 'or' group of expression a condition served by an index
+// of table, nil when indexes cannot serve every group, caller holds the lock of table
+func planFilterIndexes(tablePieces []string, expression filterExpression) (*tableIndexes, *models.TableSchema, []filterCondition, error) {
+	indexes := readFreshTableIndexes(tablePieces)
+	if indexes == nil {
+		return nil, nil, nil, nil
+	}
+	schema, err := readTableSchema(tablePieces)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if len(schema.Columns) == 0 {
+		// columns of tables with no schema are only known by reading every row
+		return nil, nil, nil, nil
+	}
+
+	var served []filterCondition
+	for _, group := range expression {
+		groupServed := false
+		for _, condition := range group {
+			if index := indexes.index(condition.column); index != nil && index.serves(condition.operator) {
+				served = append(served, condition)
+				groupServed = true
+				break
+			}
+		}
+		if !groupServed {
+			return nil, nil, nil, nil
+		}
+	}
+	return indexes, schema, served, nil
+}
+
+// loadFilterCandidates reads rows which can match expression through indexes of table,
+// nil when indexes cannot serve every 'or' group of expression,
+// caller holds the lock of table
+func loadFilterCandidates(tablePieces []string, expression filterExpression) (*models.DataTable, error) {
+	indexes, schema, served, err := planFilterIndexes(tablePieces, expression)
+	if err != nil || served == nil {
+		return nil, err
+	}
+
+	candidates := make(map[string]bool)
+	for _, condition := range served {
+		rowIDs, _, err := indexes.index(condition.column).lookup(condition)
+		if err != nil {
+			// let the rows report the error, the way reading every row does
+			return nil, nil
+		}
+		for rowID := range rowIDs {
+			candidates[rowID] = true
+		}
+	}
+
+	tbl, err := readIndexedRecords(tablePieces, indexes, candidates)
+	if err != nil {
+		fmt.Printf("Error while reading indexed rows: (%v)\n", err)
+		return nil, errors.New(dbEngineError)
+	}
+	tbl.ApplySchema(schema)
+	return tbl, nil
+}
+
+func (db *DBEngine) filterTable() (string, error) {
+	args, page, err := parsePage(db.cmdArgs)
+	if err != nil {
+		return "", err
+	}
+	db.cmdArgs = args
+	if len(db.cmdArgs) < 1 {
+		return "", errors.New("INVALID TABLE-NAME, CANNOT FILTER TABLE")
+	}
+
+	expression, err := parseFilterExpression(db.cmdArgs[1:])
+	if err != nil {
+		return "", err
+	}
+
+	tablePieces, err := db.parseTableName()
+	if err != nil {
+		return "", err
+	}
+	lock := tableLockFor(tablePieces)
+	if err := lock.RLock(); err != nil {
+		return "", err
+	}
+	defer lock.RUnlock()
+
+	if _, err = storage.StatTable(tablePieces[0], tablePieces[1]); os.IsNotExist(err) {
+		return "", errors.New("TABLE DOES NOT EXISTS")
+	}
+	// read only rows found through indexes when possible, every row otherwise
+	tbl, err := loadFilterCandidates(tablePieces, expression)
+	if err != nil {
+		return "", err
+	}
+	if tbl == nil {
+		if tbl, err = loadTableFile(tablePieces); err != nil {
+			return "", err
+		}
+	}
+
+	filteredTable := models.DataTable{Records: make(map[string]models.DataRecord), Columns: tbl.Columns}
+	for _, rowID := range tbl.OrderedRowIDs() {
+		record := tbl.Records[rowID]
+		matched, err := expression.matches(record)
+		if err != nil {
+			return "", err
+		}
+		if matched {
+			filteredTable.Records[rowID] = record
+			filteredTable.RowIDs = append(filteredTable.RowIDs, rowID)
+		}
+	}
+	return db.pageOutput(&filteredTable, nil, page)
+}
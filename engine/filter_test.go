@@ -0,0 +1,131 @@
+package engine
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/sushilkm/myYamlDB/models"
+)
+
+func TestParseFilterExpression(t *testing.T) {
+	tests := []struct {
+		args       string
+		expression filterExpression
+		err        string
+	}{
+		{args: "age = 3", expression: filterExpression{{{column: "age", operator: "=", value: "3"}}}},
+		{args: "name prefix an", expression: filterExpression{{{column: "name", operator: "PREFIX", value: "an"}}}},
+		{args: "tags contains a", expression: filterExpression{{{column: "tags", operator: "CONTAINS", value: "a"}}}},
+		{args: "address.city != pune", expression: filterExpression{{{column: "address.city", operator: "!=", value: "pune"}}}},
+		{
+			args: "age >= 3 and age < 10",
+			expression: filterExpression{{
+				{column: "age", operator: ">=", value: "3"}, {column: "age", operator: "<", value: "10"}}},
+		},
+		{
+			// 'and' binds tighter than 'or'
+			args: "a = 1 OR b = 2 AND c = 3",
+			expression: filterExpression{
+				{{column: "a", operator: "=", value: "1"}},
+				{{column: "b", operator: "=", value: "2"}, {column: "c", operator: "=", value: "3"}}},
+		},
+		{args: "", err: "NO FILTER CONDITION PROVIDED"},
+		{args: "age =", err: "INCOMPLETE FILTER CONDITION, EXPECTED <COLUMN> <OPERATOR> <VALUE>"},
+		{args: "age = 3 and", err: "INCOMPLETE FILTER CONDITION, EXPECTED <COLUMN> <OPERATOR> <VALUE>"},
+		{args: "age = 3 and age", err: "INCOMPLETE FILTER CONDITION, EXPECTED <COLUMN> <OPERATOR> <VALUE>"},
+		{args: "age ~ 3", err: "INVALID FILTER OPERATOR '~'"},
+		{args: "age = 3 xor age = 4", err: "INVALID FILTER CONJUNCTION 'xor', EXPECTED 'AND' OR 'OR'"},
+		{args: "tags[ = 3", err: "INVALID FILTER COLUMN 'tags['"},
+	}
+	for _, test := range tests {
+		expression, err := parseFilterExpression(strings.Fields(test.args))
+		if test.err != "" {
+			if err == nil || err.Error() != test.err {
+				t.Errorf("parseFilterExpression(%q) returned error %v, expected %q", test.args, err, test.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseFilterExpression(%q) failed: %v", test.args, err)
+			continue
+		}
+		if !reflect.DeepEqual(expression, test.expression) {
+			t.Errorf("parseFilterExpression(%q) = %v, expected %v", test.args, expression, test.expression)
+		}
+	}
+}
+
+func TestFilterExpressionMatches(t *testing.T) {
+	record := models.DataRecord{Columns: map[string]models.DataColumn{
+		"name":    {ColumnData: "ann"},
+		"age":     {ColumnData: 30},
+		"score":   {ColumnData: 2.5},
+		"active":  {ColumnData: true},
+		"nothing": {ColumnData: nil},
+		"address": {ColumnData: map[string]interface{}{"city": "pune"}},
+		"tags":    {ColumnData: []interface{}{"a", 7}},
+	}}
+	tests := []struct {
+		args    string
+		matches bool
+		err     string
+	}{
+		{args: "name = ann", matches: true},
+		{args: "name != ann", matches: false},
+		{args: "name < bob", matches: true},
+		// integers are compared as numbers, not as text
+		{args: "age = 30", matches: true},
+		{args: "age > 4", matches: true},
+		{args: "age < 100", matches: true},
+		{args: "age = 30.0", matches: true},
+		{args: "age <= 29.5", matches: false},
+		{args: "score > 2", matches: true},
+		{args: "score >= 2.5", matches: true},
+		{args: "score < 10", matches: true},
+		{args: "active = true", matches: true},
+		{args: "active != false", matches: true},
+		{args: "address.city = pune", matches: true},
+		{args: "tags[1] = 7", matches: true},
+		{args: "tags contains a", matches: true},
+		{args: "tags contains 7", matches: true},
+		{args: "tags contains b", matches: false},
+		{args: "name contains n", matches: true},
+		{args: "name prefix an", matches: true},
+		{args: "name prefix n", matches: false},
+		{args: "age prefix 3", matches: true},
+		// missing columns and null values never match
+		{args: "missing = ann", matches: false},
+		{args: "missing != ann", matches: false},
+		{args: "nothing = ann", matches: false},
+		{args: "address.zip = 1", matches: false},
+		{args: "age = 3 or name = ann", matches: true},
+		{args: "age = 30 and name = bob", matches: false},
+		{args: "age = 3 or age = 30 and name = ann", matches: true},
+		{args: "age = x", err: "INVALID FILTER VALUE 'x' FOR COLUMN 'age'"},
+		{args: "score > x", err: "INVALID FILTER VALUE 'x' FOR COLUMN 'score'"},
+		{args: "active = maybe", err: "INVALID FILTER VALUE 'maybe' FOR COLUMN 'active'"},
+		{args: "address = pune", err: "INVALID FILTER VALUE 'pune' FOR COLUMN 'address'"},
+	}
+	for _, test := range tests {
+		expression, err := parseFilterExpression(strings.Fields(test.args))
+		if err != nil {
+			t.Errorf("parseFilterExpression(%q) failed: %v", test.args, err)
+			continue
+		}
+		matches, err := expression.matches(record)
+		if test.err != "" {
+			if err == nil || err.Error() != test.err {
+				t.Errorf("matches(%q) returned error %v, expected %q", test.args, err, test.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("matches(%q) failed: %v", test.args, err)
+			continue
+		}
+		if matches != test.matches {
+			t.Errorf("matches(%q) = %v, expected %v", test.args, matches, test.matches)
+		}
+	}
+}
@@ -0,0 +1,35 @@
+//go:build !windows
+// +build !windows
+
+package engine
+
+import (
+	"os"
+	"syscall"
+)
+
+// lockFile takes advisory lock on file, shared or exclusive, waiting for
+// other processes to release it when wait is set, errFileLocked otherwise
+func lockFile(f *os.File, exclusive bool, wait bool) error {
+	how := syscall.LOCK_SH
+	if exclusive {
+		how = syscall.LOCK_EX
+	}
+	if !wait {
+		how |= syscall.LOCK_NB
+	}
+	for {
+		err := syscall.Flock(int(f.Fd()), how)
+		if err == syscall.EINTR {
+			continue
+		}
+		if err == syscall.EWOULDBLOCK {
+			return errFileLocked
+		}
+		return err
+	}
+}
+
+func unlockFile(f *os.File) error {
+	return syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+}
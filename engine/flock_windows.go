@@ -0,0 +1,53 @@
+package engine
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+var (
+	kernel32         = syscall.NewLazyDLL("kernel32.dll")
+	procLockFileEx   = kernel32.NewProc("LockFileEx")
+	procUnlockFileEx = kernel32.NewProc("UnlockFileEx")
+)
+
+const (
+	lockfileFailImmediately = 0x00000001
+	lockfileExclusiveLock   = 0x00000002
+	errorLockViolation      = syscall.Errno(33)
+)
+
+// lockedRange overlapped structure locating the byte locked in every lock file, windows locks
+// keep other processes from reading locked bytes, so the byte is far beyond the content of file
+func lockedRange() *syscall.Overlapped {
+	return &syscall.Overlapped{OffsetHigh: 0x40000000}
+}
+
+// lockFile takes lock on file with LockFileEx, shared or exclusive, waiting for
+// other processes to release it when wait is set, errFileLocked otherwise
+func lockFile(f *os.File, exclusive bool, wait bool) error {
+	var flags uintptr
+	if exclusive {
+		flags |= lockfileExclusiveLock
+	}
+	if !wait {
+		flags |= lockfileFailImmediately
+	}
+	result, _, err := procLockFileEx.Call(f.Fd(), flags, 0, 1, 0, uintptr(unsafe.Pointer(lockedRange())))
+	if result != 0 {
+		return nil
+	}
+	if err == errorLockViolation || err == syscall.ERROR_IO_PENDING {
+		return errFileLocked
+	}
+	return err
+}
+
+func unlockFile(f *os.File) error {
+	result, _, err := procUnlockFileEx.Call(f.Fd(), 0, 1, 0, uintptr(unsafe.Pointer(lockedRange())))
+	if result != 0 {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,913 @@
+package engine
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/sushilkm/myYamlDB/models"
+	"gopkg.in/yaml.v2"
+)
+
+// Index command format is
+// create-index <db-name>:<table-name> <column> [hash|ordered]
+// drop-index <db-name>:<table-name> <column>
+// list-indexes <db-name>:<table-name>
+// Indexes of a table are kept in storage next to its records, along with
+// the location of the last record of every live row in table records, so that rows
+// found through an index are read without parsing the whole table.
+// Hash index serves '=' conditions, ordered index serves '=', '<', '<=', '>'
+// and '>=' conditions and sorting, column can address nested data.
+// Indexes are kept in memory once read, along with the state of table records they are
+// up to date with. Appends update them in memory and they are written to storage every
+// indexFlushInterval appends and whenever they are built, indexes read from storage not
+// matching the size of table records, e.g. after a restart or a crash, are rebuilt
+
+// Index types
+const (
+	indexHash    = "hash"
+	indexOrdered = "ordered"
+)
+
+// orderedIndexOperators filter operators served by ordered index
+var orderedIndexOperators = map[string]bool{
+	"=":  true,
+	"<":  true,
+	"<=": true,
+	">":  true,
+	">=": true,
+}
+
+// indexFlushInterval number of appends applied to indexes in memory before they are written to storage
+const indexFlushInterval = 64
+
+// rowLocation locates last record of a row in table records
+type rowLocation struct {
+	RowID  string `yaml:"row_id"`
+	Offset int64  `yaml:"offset"`
+	Length int64  `yaml:"length"`
+	// position of row in table order
+	position int
+}
+
+// indexEntry lists rows holding a value in indexed column,
+// values comparing equal share their entry
+type indexEntry struct {
+	Value  interface{} `yaml:"value"`
+	RowIDs []string    `yaml:"row_ids"`
+	// rows set of row-ids of entry, RowIDs are listed from it when indexes are written
+	rows map[string]bool
+}
+
+type tableIndex struct {
+	Column string `yaml:"column"`
+	Type   string `yaml:"type"`
+	// Entries of ordered index are sorted by value,
+	// entries of hash index are listed from entries when indexes are written
+	Entries []*indexEntry `yaml:"entries"`
+	// entries by key of their value
+	entries map[string]*indexEntry
+	// rowKeys key of entry listing every indexed row
+	rowKeys map[string]string
+	// kinds number of entries holding every kind of value
+	kinds map[string]int
+}
+
+type tableIndexes struct {
+	// TableSize size of table records indexes are up to date with
+	TableSize int64 `yaml:"table_size"`
+	// Rows location of every live row, in table order,
+	// listed from locations when indexes are written
+	Rows    []rowLocation `yaml:"rows"`
+	Indexes []tableIndex  `yaml:"indexes"`
+	// locations of live rows by row-id
+	locations map[string]rowLocation
+	// nextPosition position of the next row written to table
+	nextPosition int
+}
+
+// cachedIndexes indexes of a table kept in memory, nil indexes when table has none
+type cachedIndexes struct {
+	state   TableState
+	indexes *tableIndexes
+	// unwritten number of appends applied since indexes were written to storage
+	unwritten int
+}
+
+// indexCache indexes of tables by table key, read holding the lock of table
+// and changed holding the write lock
+var indexCache = struct {
+	sync.Mutex
+	entries map[string]*cachedIndexes
+}{entries: make(map[string]*cachedIndexes)}
+
+func cachedTableIndexes(tablePieces []string) *cachedIndexes {
+	indexCache.Lock()
+	defer indexCache.Unlock()
+	return indexCache.entries[tableKey(tablePieces)]
+}
+
+func storeTableIndexes(tablePieces []string, entry *cachedIndexes) {
+	indexCache.Lock()
+	defer indexCache.Unlock()
+	indexCache.entries[tableKey(tablePieces)] = entry
+}
+
+func dropTableIndexes(tablePieces []string) {
+	indexCache.Lock()
+	defer indexCache.Unlock()
+	delete(indexCache.entries, tableKey(tablePieces))
+}
+
+// indexKey returns key of column value in index, values comparing equal share their key,
+// integers are keyed exactly and floats holding an integer share the key of the integer
+func indexKey(value interface{}) string {
+	switch data := value.(type) {
+	case nil:
+		return "nil"
+	case int:
+		return "n:" + strconv.FormatInt(int64(data), 10)
+	case int64:
+		return "n:" + strconv.FormatInt(data, 10)
+	case uint64:
+		return "n:" + strconv.FormatUint(data, 10)
+	case float64:
+		switch {
+		case data != math.Trunc(data):
+		case data >= math.MinInt64 && data < math.MaxInt64:
+			return "n:" + strconv.FormatInt(int64(data), 10)
+		case data >= 0 && data < math.MaxUint64:
+			return "n:" + strconv.FormatUint(uint64(data), 10)
+		}
+		return "n:" + strconv.FormatFloat(data, 'g', -1, 64)
+	case string:
+		return "s:" + data
+	case bool:
+		return "b:" + strconv.FormatBool(data)
+	}
+	return "x:" + models.DataColumn{ColumnData: value}.String()
+}
+
+// indexKind returns kind of value keyed by index key
+func indexKind(key string) string {
+	if key == "nil" {
+		return key
+	}
+	return key[:2]
+}
+
+// filterIndexKeys returns keys of every column value a filter value can be equal to
+func filterIndexKeys(text string) []string {
+	keys := []string{indexKey(text)}
+	if number, err := strconv.ParseInt(text, 10, 64); err == nil {
+		keys = append(keys, indexKey(number))
+	} else if number, err := strconv.ParseUint(text, 10, 64); err == nil {
+		keys = append(keys, indexKey(number))
+	} else if number, err := strconv.ParseFloat(text, 64); err == nil {
+		keys = append(keys, indexKey(number))
+	}
+	if boolean, err := strconv.ParseBool(text); err == nil {
+		keys = append(keys, indexKey(boolean))
+	}
+	return keys
+}
+
+// compareValues compares column values the way sorting does,
+// values of different kinds are ordered by their string representation
+func compareValues(first, second interface{}) int {
+	result, err := models.CompareData(first, second)
+	if err != nil {
+		return strings.Compare(models.DataColumn{ColumnData: first}.String(), models.DataColumn{ColumnData: second}.String())
+	}
+	return result
+}
+
+// readTableIndexes reads indexes of table, nil when table has no indexes,
+// caller holds the lock of table
+func readTableIndexes(tablePieces []string) (*tableIndexes, error) {
+	indexData, err := storage.ReadIndexes(tablePieces[0], tablePieces[1])
+	if err != nil {
+		return nil, err
+	}
+	if indexData == nil {
+		return nil, nil
+	}
+
+	var indexes tableIndexes
+	if err := yaml.Unmarshal(indexData, &indexes); err != nil {
+		return nil, err
+	}
+	indexes.locations = make(map[string]rowLocation, len(indexes.Rows))
+	for position, location := range indexes.Rows {
+		location.position = position
+		indexes.locations[location.RowID] = location
+	}
+	indexes.nextPosition = len(indexes.Rows)
+	indexes.Rows = nil
+	for i := range indexes.Indexes {
+		index := &indexes.Indexes[i]
+		entries := index.Entries
+		index.reset()
+		for _, entry := range entries {
+			entry.Value = models.NormalizeData(entry.Value)
+			key := indexKey(entry.Value)
+			entry.rows = make(map[string]bool, len(entry.RowIDs))
+			for _, rowID := range entry.RowIDs {
+				entry.rows[rowID] = true
+				index.rowKeys[rowID] = key
+			}
+			entry.RowIDs = nil
+			index.entries[key] = entry
+			index.kinds[indexKind(key)]++
+			if index.Type == indexOrdered {
+				index.Entries = append(index.Entries, entry)
+			}
+		}
+	}
+	return &indexes, nil
+}
+
+// loadTableIndexes returns indexes of table up to date with table records, from memory when
+// they are, nil when table has no indexes, caller holds the lock of table and does not change them
+func loadTableIndexes(tablePieces []string) (*tableIndexes, error) {
+	state, err := storage.StatTable(tablePieces[0], tablePieces[1])
+	if err != nil {
+		return nil, err
+	}
+	if entry := cachedTableIndexes(tablePieces); entry != nil && entry.state == state {
+		return entry.indexes, nil
+	}
+
+	indexes, err := readTableIndexes(tablePieces)
+	if err != nil {
+		return nil, err
+	}
+	entry := &cachedIndexes{state: state, indexes: indexes}
+	if indexes != nil && indexes.TableSize != state.Size {
+		// indexes missed appends, they are rebuilt in memory and written by the next write
+		if entry.indexes, err = buildTableIndexes(tablePieces, indexes.Indexes); err != nil {
+			return nil, err
+		}
+		entry.unwritten = indexFlushInterval
+	}
+	storeTableIndexes(tablePieces, entry)
+	return entry.indexes, nil
+}
+
+// readFreshTableIndexes returns indexes of table up to date with table records,
+// nil when table has no indexes or they cannot be read, caller holds the lock of table
+func readFreshTableIndexes(tablePieces []string) *tableIndexes {
+	indexes, err := loadTableIndexes(tablePieces)
+	if err != nil {
+		fmt.Printf("Error while reading indexes: (%v)\n", err)
+		return nil
+	}
+	return indexes
+}
+
+// writeTableIndexes writes indexes up to date with table records to storage,
+// and keeps them in memory, caller holds the write lock of table
+func writeTableIndexes(tablePieces []string, indexes *tableIndexes) error {
+	dropTableIndexes(tablePieces)
+	if len(indexes.Indexes) == 0 {
+		return storage.WriteIndexes(tablePieces[0], tablePieces[1], nil)
+	}
+
+	indexes.Rows = make([]rowLocation, 0, len(indexes.locations))
+	for _, location := range indexes.locations {
+		indexes.Rows = append(indexes.Rows, location)
+	}
+	sort.Slice(indexes.Rows, func(i, j int) bool { return indexes.Rows[i].position < indexes.Rows[j].position })
+	for i := range indexes.Indexes {
+		index := &indexes.Indexes[i]
+		if index.Type == indexHash {
+			keys := make([]string, 0, len(index.entries))
+			for key := range index.entries {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+			for _, key := range keys {
+				index.Entries = append(index.Entries, index.entries[key])
+			}
+		}
+		for _, entry := range index.Entries {
+			for rowID := range entry.rows {
+				entry.RowIDs = append(entry.RowIDs, rowID)
+			}
+			sort.Strings(entry.RowIDs)
+		}
+	}
+	indexData, err := yaml.Marshal(indexes)
+
+	// listed fields are only kept for writing
+	indexes.Rows = nil
+	for i := range indexes.Indexes {
+		index := &indexes.Indexes[i]
+		if index.Type == indexHash {
+			index.Entries = nil
+		}
+		for _, entry := range index.Entries {
+			entry.RowIDs = nil
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if err := storage.WriteIndexes(tablePieces[0], tablePieces[1], indexData); err != nil {
+		return err
+	}
+
+	state, err := storage.StatTable(tablePieces[0], tablePieces[1])
+	if err == nil && state.Size == indexes.TableSize {
+		storeTableIndexes(tablePieces, &cachedIndexes{state: state, indexes: indexes})
+	}
+	return nil
+}
+
+// index returns index on column, nil when column is not indexed
+func (indexes *tableIndexes) index(column string) *tableIndex {
+	if indexes == nil {
+		return nil
+	}
+	for i := range indexes.Indexes {
+		if indexes.Indexes[i].Column == column {
+			return &indexes.Indexes[i]
+		}
+	}
+	return nil
+}
+
+// setRow records location of last record of row, a new row is listed last
+func (indexes *tableIndexes) setRow(location rowLocation) {
+	if current, ok := indexes.locations[location.RowID]; ok {
+		location.position = current.position
+	} else {
+		location.position = indexes.nextPosition
+		indexes.nextPosition++
+	}
+	indexes.locations[location.RowID] = location
+}
+
+func (indexes *tableIndexes) deleteRow(rowID string) {
+	delete(indexes.locations, rowID)
+}
+
+// reset empties index
+func (index *tableIndex) reset() {
+	index.Entries = nil
+	index.entries = make(map[string]*indexEntry)
+	index.rowKeys = make(map[string]string)
+	index.kinds = make(map[string]int)
+}
+
+// addRow adds row to index, rows missing indexed column are left out
+func (index *tableIndex) addRow(rowID string, record models.DataRecord) {
+	column, ok := record.Lookup(index.Column)
+	if !ok {
+		return
+	}
+	key := indexKey(column.ColumnData)
+	index.rowKeys[rowID] = key
+	if entry, ok := index.entries[key]; ok {
+		entry.rows[rowID] = true
+		return
+	}
+
+	entry := &indexEntry{Value: column.ColumnData, rows: map[string]bool{rowID: true}}
+	index.entries[key] = entry
+	index.kinds[indexKind(key)]++
+	if index.Type != indexOrdered {
+		return
+	}
+	position := sort.Search(len(index.Entries), func(i int) bool {
+		return compareValues(index.Entries[i].Value, entry.Value) > 0
+	})
+	index.Entries = append(index.Entries, nil)
+	copy(index.Entries[position+1:], index.Entries[position:])
+	index.Entries[position] = entry
+}
+
+func (index *tableIndex) deleteRow(rowID string) {
+	key, ok := index.rowKeys[rowID]
+	if !ok {
+		return
+	}
+	delete(index.rowKeys, rowID)
+	entry := index.entries[key]
+	delete(entry.rows, rowID)
+	if len(entry.rows) > 0 {
+		return
+	}
+
+	delete(index.entries, key)
+	if index.kinds[indexKind(key)]--; index.kinds[indexKind(key)] == 0 {
+		delete(index.kinds, indexKind(key))
+	}
+	if index.Type != indexOrdered {
+		return
+	}
+	// entries comparing equal to entry are next to it
+	position := sort.Search(len(index.Entries), func(i int) bool {
+		return compareValues(index.Entries[i].Value, entry.Value) >= 0
+	})
+	for position < len(index.Entries) && index.Entries[position] != entry {
+		position++
+	}
+	if position < len(index.Entries) {
+		index.Entries = append(index.Entries[:position], index.Entries[position+1:]...)
+	}
+}
+
+// parseTableRecord parses a single record of table records,
+// returns its row-id and record, nil record for a deleted row
+func parseTableRecord(recordData []byte) (string, *models.DataRecord, error) {
+	rowIDs, valid := models.ParseYamlRowIDs(recordData)
+	if !valid || len(rowIDs) != 1 {
+		return "", nil, errors.New("INVALID TABLE DATA")
+	}
+	tbl, valid := models.ParseYaml(recordData)
+	if !valid {
+		return "", nil, errors.New("INVALID TABLE DATA")
+	}
+	for rowID := range rowIDs {
+		if record, ok := tbl.Records[rowID]; ok {
+			return rowID, &record, nil
+		}
+		return rowID, nil, nil
+	}
+	return "", nil, nil
+}
+
+// splitTableRecords returns location of every record in table records,
+// every record written to table starts with an unindented row-id line
+func splitTableRecords(tableData []byte) []rowLocation {
+	var locations []rowLocation
+	var offset int64
+	if bytes.HasPrefix(tableData, []byte(emptyTableMarker)) {
+		offset = int64(len(emptyTableMarker))
+	}
+	for offset < int64(len(tableData)) {
+		end := offset
+		for {
+			newLine := bytes.IndexByte(tableData[end:], '\n')
+			if newLine == -1 {
+				end = int64(len(tableData))
+				break
+			}
+			end += int64(newLine) + 1
+			if end == int64(len(tableData)) {
+				break
+			}
+			if next := tableData[end]; next != ' ' && next != '\t' && next != '\n' && next != '#' {
+				break
+			}
+		}
+		locations = append(locations, rowLocation{Offset: offset, Length: end - offset})
+		offset = end
+	}
+	return locations
+}
+
+// buildTableIndexes builds indexes from table records, caller holds the lock of table
+func buildTableIndexes(tablePieces []string, definitions []tableIndex) (*tableIndexes, error) {
+	tableData, err := storage.ReadRecords(tablePieces[0], tablePieces[1])
+	if err != nil {
+		return nil, err
+	}
+
+	// rows are listed at their first position, a row deleted and written again is listed last
+	var rowIDs []string
+	positions := make(map[string]int)
+	locations := make(map[string]rowLocation)
+	records := make(map[string]models.DataRecord)
+	for _, location := range splitTableRecords(tableData) {
+		rowID, record, err := parseTableRecord(tableData[location.Offset : location.Offset+location.Length])
+		if err != nil {
+			return nil, err
+		}
+		if record == nil {
+			delete(locations, rowID)
+			delete(records, rowID)
+			continue
+		}
+		if _, ok := locations[rowID]; !ok {
+			positions[rowID] = len(rowIDs)
+			rowIDs = append(rowIDs, rowID)
+		}
+		location.RowID = rowID
+		locations[rowID] = location
+		records[rowID] = *record
+	}
+
+	indexes := &tableIndexes{TableSize: int64(len(tableData)), locations: make(map[string]rowLocation, len(locations))}
+	var liveRowIDs []string
+	for position, rowID := range rowIDs {
+		if location, ok := locations[rowID]; ok && positions[rowID] == position {
+			indexes.setRow(location)
+			liveRowIDs = append(liveRowIDs, rowID)
+		}
+	}
+	for _, definition := range definitions {
+		index := tableIndex{Column: definition.Column, Type: definition.Type}
+		index.reset()
+		for _, rowID := range liveRowIDs {
+			column, ok := records[rowID].Lookup(index.Column)
+			if !ok {
+				continue
+			}
+			key := indexKey(column.ColumnData)
+			index.rowKeys[rowID] = key
+			if entry, ok := index.entries[key]; ok {
+				entry.rows[rowID] = true
+				continue
+			}
+			entry := &indexEntry{Value: column.ColumnData, rows: map[string]bool{rowID: true}}
+			index.entries[key] = entry
+			index.kinds[indexKind(key)]++
+			if index.Type == indexOrdered {
+				index.Entries = append(index.Entries, entry)
+			}
+		}
+		if index.Type == indexOrdered {
+			sort.SliceStable(index.Entries, func(i, j int) bool {
+				return compareValues(index.Entries[i].Value, index.Entries[j].Value) < 0
+			})
+		}
+		indexes.Indexes = append(indexes.Indexes, index)
+	}
+	return indexes, nil
+}
+
+// rebuildIndexes rebuilds indexes of table from table records, after table is rewritten,
+// caller holds the lock of table
+func rebuildIndexes(tablePieces []string) error {
+	indexes, err := readTableIndexes(tablePieces)
+	if err != nil || indexes == nil {
+		return err
+	}
+	if indexes, err = buildTableIndexes(tablePieces, indexes.Indexes); err != nil {
+		return err
+	}
+	return writeTableIndexes(tablePieces, indexes)
+}
+
+// updateIndexes updates indexes of table in memory with record appended at offset to table records
+// and writes them to storage every indexFlushInterval appends, indexes are dropped from memory
+// on failure, to be rebuilt by the next command, caller holds the write lock of table
+func updateIndexes(tablePieces []string, rowID string, recordData []byte, offset int64) error {
+	state, err := storage.StatTable(tablePieces[0], tablePieces[1])
+	if err != nil {
+		dropTableIndexes(tablePieces)
+		return err
+	}
+	entry := cachedTableIndexes(tablePieces)
+	if entry == nil || entry.state.Generation != state.Generation ||
+		(entry.indexes == nil && entry.state.Size != offset) ||
+		(entry.indexes != nil && entry.indexes.TableSize != offset) {
+		// indexes in memory missed an earlier write, read them again
+		indexes, err := readTableIndexes(tablePieces)
+		if err != nil {
+			dropTableIndexes(tablePieces)
+			return err
+		}
+		entry = &cachedIndexes{state: state, indexes: indexes, unwritten: indexFlushInterval}
+		if indexes == nil {
+			storeTableIndexes(tablePieces, entry)
+			return nil
+		}
+	}
+	if entry.indexes == nil {
+		entry.state = state
+		return nil
+	}
+
+	indexes := entry.indexes
+	if indexes.TableSize != offset {
+		if indexes, err = buildTableIndexes(tablePieces, indexes.Indexes); err != nil {
+			dropTableIndexes(tablePieces)
+			return err
+		}
+	} else {
+		_, record, err := parseTableRecord(recordData)
+		if err != nil {
+			dropTableIndexes(tablePieces)
+			return err
+		}
+		for i := range indexes.Indexes {
+			indexes.Indexes[i].deleteRow(rowID)
+		}
+		if record == nil {
+			indexes.deleteRow(rowID)
+		} else {
+			indexes.setRow(rowLocation{RowID: rowID, Offset: offset, Length: int64(len(recordData))})
+			for i := range indexes.Indexes {
+				indexes.Indexes[i].addRow(rowID, *record)
+			}
+		}
+		indexes.TableSize = offset + int64(len(recordData))
+	}
+
+	if entry.unwritten+1 >= indexFlushInterval {
+		if err := writeTableIndexes(tablePieces, indexes); err != nil {
+			dropTableIndexes(tablePieces)
+			return err
+		}
+		return nil
+	}
+	storeTableIndexes(tablePieces, &cachedIndexes{state: state, indexes: indexes, unwritten: entry.unwritten + 1})
+	return nil
+}
+
+// readIndexedRecords reads records of rows from table records, in table order, using locations
+// recorded in indexes, caller holds the lock of table
+func readIndexedRecords(tablePieces []string, indexes *tableIndexes, rowIDs map[string]bool) (*models.DataTable, error) {
+	locations := make([]rowLocation, 0, len(rowIDs))
+	for rowID := range rowIDs {
+		if location, ok := indexes.locations[rowID]; ok {
+			locations = append(locations, location)
+		}
+	}
+	sort.Slice(locations, func(i, j int) bool { return locations[i].position < locations[j].position })
+
+	tbl := &models.DataTable{Records: make(map[string]models.DataRecord)}
+	for _, location := range locations {
+		recordData, err := storage.ReadRecordsAt(tablePieces[0], tablePieces[1], location.Offset, location.Length)
+		if err != nil {
+			return nil, err
+		}
+		rowID, record, err := parseTableRecord(recordData)
+		if err != nil {
+			return nil, err
+		}
+		if rowID != location.RowID || record == nil {
+			return nil, errors.New("INDEX DOES NOT MATCH TABLE DATA")
+		}
+		tbl.Records[rowID] = *record
+		tbl.RowIDs = append(tbl.RowIDs, rowID)
+	}
+	return tbl, nil
+}
+
+// serves checks if index can look up rows of conditions with operator
+func (index *tableIndex) serves(operator string) bool {
+	return (operator == "=" && index.Type == indexHash) || (index.Type == indexOrdered && orderedIndexOperators[operator])
+}
+
+// lookup returns rows of index matching filter condition,
+// ok is false when index cannot serve the condition
+func (index *tableIndex) lookup(condition filterCondition) (map[string]bool, bool, error) {
+	rowIDs := make(map[string]bool)
+	addEntry := func(entry *indexEntry) {
+		for rowID := range entry.rows {
+			rowIDs[rowID] = true
+		}
+	}
+	switch {
+	case condition.operator == "=" && index.Type == indexHash:
+		for _, key := range filterIndexKeys(condition.value) {
+			if entry, ok := index.entries[key]; ok {
+				addEntry(entry)
+			}
+		}
+	case index.Type == indexOrdered && orderedIndexOperators[condition.operator]:
+		first, last, searched, err := index.searchRange(condition)
+		if err != nil {
+			return nil, false, err
+		}
+		if searched {
+			for _, entry := range index.Entries[first:last] {
+				addEntry(entry)
+			}
+			break
+		}
+		// entries of different kinds are not ordered the way conditions compare them
+		for _, entry := range index.Entries {
+			matched, err := condition.matches(entryRecord(condition, entry))
+			if err != nil {
+				return nil, false, err
+			}
+			if matched {
+				addEntry(entry)
+			}
+		}
+	default:
+		return nil, false, nil
+	}
+	return rowIDs, true, nil
+}
+
+// entryRecord returns a record holding value of index entry in condition column
+func entryRecord(condition filterCondition, entry *indexEntry) models.DataRecord {
+	return models.DataRecord{Columns: map[string]models.DataColumn{condition.column: {ColumnData: entry.Value}}}
+}
+
+// searchRange returns range of entries of ordered index matching condition, found by binary search,
+// searched is false when entries hold values of more than one kind and are to be matched one by one
+func (index *tableIndex) searchRange(condition filterCondition) (int, int, bool, error) {
+	kind := ""
+	for entryKind := range index.kinds {
+		if entryKind == "nil" {
+			continue
+		}
+		if kind != "" {
+			return 0, 0, false, nil
+		}
+		kind = entryKind
+	}
+	if kind == "" {
+		// null values match no condition
+		return 0, 0, true, nil
+	}
+	if kind != "n:" && kind != "s:" && kind != "b:" {
+		return 0, 0, false, nil
+	}
+
+	// null values are ordered first, the last entry holds a value of the kind
+	last := index.Entries[len(index.Entries)-1]
+	if _, err := condition.matches(entryRecord(condition, last)); err != nil {
+		return 0, 0, false, err
+	}
+	value, _ := models.ParseData(last.Value, condition.value)
+	compare := func(i int) int {
+		result, _ := models.CompareData(index.Entries[i].Value, value)
+		return result
+	}
+	first := sort.Search(len(index.Entries), func(i int) bool { return index.Entries[i].Value != nil })
+	equalFirst := sort.Search(len(index.Entries), func(i int) bool { return i >= first && compare(i) >= 0 })
+	equalLast := sort.Search(len(index.Entries), func(i int) bool { return i >= first && compare(i) > 0 })
+	switch condition.operator {
+	case "=":
+		return equalFirst, equalLast, true, nil
+	case "<":
+		return first, equalFirst, true, nil
+	case "<=":
+		return first, equalLast, true, nil
+	case ">":
+		return equalLast, len(index.Entries), true, nil
+	}
+	return equalFirst, len(index.Entries), true, nil
+}
+
+// ranks returns rank of every row in ordered index,
+// rows holding values comparing equal share their rank
+func (index *tableIndex) ranks() map[string]int {
+	ranks := make(map[string]int)
+	rank := 0
+	for i, entry := range index.Entries {
+		if i > 0 && compareValues(index.Entries[i-1].Value, entry.Value) != 0 {
+			rank++
+		}
+		for rowID := range entry.rows {
+			ranks[rowID] = rank
+		}
+	}
+	return ranks
+}
+
+// parseIndexArguments checks index command arguments and returns table-name pieces and column
+func (db *DBEngine) parseIndexArguments() ([]string, string, error) {
+	if len(db.cmdArgs) < 2 {
+		return nil, "", errors.New("INVALID NUMBER OF ARGUMENTS")
+	}
+	tablePieces, err := db.parseTableName()
+	if err != nil {
+		return nil, "", err
+	}
+	if err := models.ValidateColumnPath(db.cmdArgs[1]); err != nil {
+		return nil, "", fmt.Errorf("INVALID INDEX COLUMN '%s'", db.cmdArgs[1])
+	}
+	return tablePieces, db.cmdArgs[1], nil
+}
+
+func (db *DBEngine) createIndex() (string, error) {
+	if len(db.cmdArgs) > 3 {
+		return "", errors.New("INVALID NUMBER OF ARGUMENTS")
+	}
+	tablePieces, column, err := db.parseIndexArguments()
+	if err != nil {
+		return "", err
+	}
+	indexType := indexHash
+	if len(db.cmdArgs) == 3 {
+		indexType = strings.ToLower(db.cmdArgs[2])
+		if indexType != indexHash && indexType != indexOrdered {
+			return "", fmt.Errorf("INVALID INDEX TYPE '%s', EXPECTED 'HASH' OR 'ORDERED'", db.cmdArgs[2])
+		}
+	}
+
+	lock := tableLockFor(tablePieces)
+	if err := lock.Lock(); err != nil {
+		return "", err
+	}
+	defer lock.Unlock()
+
+	if _, err = storage.StatTable(tablePieces[0], tablePieces[1]); os.IsNotExist(err) {
+		return "", errors.New("TABLE DOES NOT EXISTS")
+	}
+	schema, err := readTableSchema(tablePieces)
+	if err != nil {
+		return "", err
+	}
+	if columnName, _ := models.ColumnPathRoot(column); len(schema.Columns) > 0 && !schema.HasColumn(columnName) {
+		return "", fmt.Errorf("COLUMN '%s' DOES NOT EXISTS", columnName)
+	}
+	indexes, err := readTableIndexes(tablePieces)
+	if err != nil {
+		fmt.Printf("Error while reading indexes: (%v)\n", err)
+		return "", errors.New(dbEngineError)
+	}
+	if indexes.index(column) != nil {
+		return "", fmt.Errorf("INDEX ON COLUMN '%s' ALREADY EXISTS", column)
+	}
+
+	var definitions []tableIndex
+	if indexes != nil {
+		definitions = indexes.Indexes
+	}
+	definitions = append(definitions, tableIndex{Column: column, Type: indexType})
+	if indexes, err = buildTableIndexes(tablePieces, definitions); err != nil {
+		fmt.Printf("Error while creating index: (%v)\n", err)
+		return "", errors.New(dbEngineError)
+	}
+	if err := writeTableIndexes(tablePieces, indexes); err != nil {
+		fmt.Printf("Error while creating index: (%v)\n", err)
+		return "", errors.New(dbEngineError)
+	}
+
+	return fmt.Sprintf(`INDEX ON COLUMN '%s' OF TABLE '%s:%s' CREATED.`, column, tablePieces[0], tablePieces[1]), nil
+}
+
+func (db *DBEngine) dropIndex() (string, error) {
+	tablePieces, column, err := db.parseIndexArguments()
+	if err != nil {
+		return "", err
+	}
+
+	lock := tableLockFor(tablePieces)
+	if err := lock.Lock(); err != nil {
+		return "", err
+	}
+	defer lock.Unlock()
+
+	if _, err = storage.StatTable(tablePieces[0], tablePieces[1]); os.IsNotExist(err) {
+		return "", errors.New("TABLE DOES NOT EXISTS")
+	}
+	indexes, err := loadTableIndexes(tablePieces)
+	if err != nil {
+		fmt.Printf("Error while reading indexes: (%v)\n", err)
+		return "", errors.New(dbEngineError)
+	}
+	if indexes.index(column) == nil {
+		return "", fmt.Errorf("INDEX ON COLUMN '%s' DOES NOT EXISTS", column)
+	}
+
+	for i := range indexes.Indexes {
+		if indexes.Indexes[i].Column == column {
+			indexes.Indexes = append(indexes.Indexes[:i], indexes.Indexes[i+1:]...)
+			break
+		}
+	}
+	if err := writeTableIndexes(tablePieces, indexes); err != nil {
+		fmt.Printf("Error while dropping index: (%v)\n", err)
+		return "", errors.New(dbEngineError)
+	}
+
+	return fmt.Sprintf(`INDEX ON COLUMN '%s' OF TABLE '%s:%s' DROPPED.`, column, tablePieces[0], tablePieces[1]), nil
+}
+
+func (db *DBEngine) listIndexes() (string, error) {
+	tablePieces, err := db.parseTableName()
+	if err != nil {
+		return "", err
+	}
+
+	lock := tableLockFor(tablePieces)
+	if err := lock.RLock(); err != nil {
+		return "", err
+	}
+	defer lock.RUnlock()
+
+	if _, err = storage.StatTable(tablePieces[0], tablePieces[1]); os.IsNotExist(err) {
+		return "", errors.New("TABLE DOES NOT EXISTS")
+	}
+	indexes, err := loadTableIndexes(tablePieces)
+	if err != nil {
+		fmt.Printf("Error while reading indexes: (%v)\n", err)
+		return "", errors.New(dbEngineError)
+	}
+	if indexes == nil || len(indexes.Indexes) == 0 {
+		return "NO INDEXES EXIST", nil
+	}
+
+	indexList := "column|type|values"
+	for _, index := range indexes.Indexes {
+		indexList += fmt.Sprintf("\n%s|%s|%d", index.Column, index.Type, len(index.entries))
+	}
+	return indexList, nil
+}
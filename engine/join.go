@@ -0,0 +1,310 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/sushilkm/myYamlDB/models"
+	"github.com/sushilkm/myYamlDB/query"
+)
+
+// Join format of select is
+// select ... from <db-name>:<table-name> [<alias>] [inner|left] join <db-name>:<table-name> [<alias>] on <column> = <column> ...
+// Joined tables are to be of the same database. Every row of the first table is combined with every
+// row of a joined table whose column is equal, a left join keeps rows no row of the joined table matches.
+// Combined rows hold the row of every table as a map under the alias or name of the table,
+// so columns are addressed as "<table>.<column>" and listed by table-qualified names.
+// Tables are joined by hashing rows of the joined table on their join column
+
+// joinSource table of a select joining tables
+type joinSource struct {
+	tablePieces []string
+	// qualifier alias or name of table, qualifying its columns
+	qualifier string
+	columns   []string
+	kind      string
+	// leftColumn path of combined rows, rightColumn path of rows of table they are joined on
+	leftColumn  string
+	rightColumn string
+}
+
+// readTableColumns reads column-names of table
+func readTableColumns(tablePieces []string) ([]string, error) {
+	lock := tableLockFor(tablePieces)
+	if err := lock.RLock(); err != nil {
+		return nil, err
+	}
+	defer lock.RUnlock()
+
+	if _, err := storage.StatTable(tablePieces[0], tablePieces[1]); os.IsNotExist(err) {
+		return nil, fmt.Errorf("TABLE '%s' DOES NOT EXISTS", strings.Join(tablePieces, ":"))
+	}
+	tbl, err := loadTableFile(tablePieces)
+	if err != nil {
+		return nil, err
+	}
+	return tbl.OrderedColumns(), nil
+}
+
+// bindColumn qualifies column path by the table holding the column,
+// column of a table is found by its qualifier or, when unqualified, in the only table having it
+func bindColumn(sources []*joinSource, column *query.Column) error {
+	columnName, err := models.ColumnPathRoot(column.Path)
+	if err != nil {
+		return fmt.Errorf("INVALID COLUMN '%s'", column.Path)
+	}
+	for _, source := range sources {
+		if !strings.EqualFold(columnName, source.qualifier) {
+			continue
+		}
+		nestedPath := strings.TrimPrefix(column.Path[len(columnName):], ".")
+		if nestedPath == "" {
+			return fmt.Errorf("COLUMN OF TABLE '%s' NOT PROVIDED", source.qualifier)
+		}
+		nestedName, err := models.ColumnPathRoot(nestedPath)
+		if err != nil {
+			return fmt.Errorf("INVALID COLUMN '%s'", column.Path)
+		}
+		if !source.hasColumn(nestedName) {
+			return fmt.Errorf("COLUMN '%s' DOES NOT EXISTS", column.Path)
+		}
+		column.Path = source.qualifier + "." + nestedPath
+		return nil
+	}
+
+	var found *joinSource
+	for _, source := range sources {
+		if !source.hasColumn(columnName) {
+			continue
+		}
+		if found != nil {
+			return fmt.Errorf("COLUMN '%s' IS AMBIGUOUS, QUALIFY IT WITH ITS TABLE", column.Path)
+		}
+		found = source
+	}
+	if found == nil {
+		return fmt.Errorf("COLUMN '%s' DOES NOT EXISTS", column.Path)
+	}
+	column.Path = found.qualifier + "." + column.Path
+	return nil
+}
+
+func (source *joinSource) hasColumn(columnName string) bool {
+	for _, name := range source.columns {
+		if name == columnName {
+			return true
+		}
+	}
+	return false
+}
+
+// bindExpression qualifies every column of expression
+func bindExpression(sources []*joinSource, expression query.Expression) error {
+	switch node := expression.(type) {
+	case *query.Column:
+		return bindColumn(sources, node)
+	case *query.Function:
+		if node.Column != nil {
+			return bindColumn(sources, node.Column)
+		}
+	case *query.Binary:
+		if err := bindExpression(sources, node.Left); err != nil {
+			return err
+		}
+		return bindExpression(sources, node.Right)
+	case *query.Not:
+		return bindExpression(sources, node.Operand)
+	case *query.IsNull:
+		return bindExpression(sources, node.Operand)
+	}
+	return nil
+}
+
+// bindJoin finds column of joined table and column of the tables before it that join condition compares
+func bindJoin(sources []*joinSource, source *joinSource, join query.Join) error {
+	condition, ok := join.On.(*query.Binary)
+	var left, right *query.Column
+	if ok && condition.Operator == query.OpEqual {
+		left, _ = condition.Left.(*query.Column)
+		right, _ = condition.Right.(*query.Column)
+	}
+	if left == nil || right == nil {
+		return fmt.Errorf("INVALID JOIN CONDITION OF TABLE '%s', EXPECTED <COLUMN> = <COLUMN>", source.qualifier)
+	}
+	if err := bindExpression(sources, join.On); err != nil {
+		return err
+	}
+
+	prefix := source.qualifier + "."
+	if strings.HasPrefix(left.Path, prefix) {
+		left, right = right, left
+	}
+	if !strings.HasPrefix(right.Path, prefix) || strings.HasPrefix(left.Path, prefix) {
+		return fmt.Errorf("JOIN CONDITION OF TABLE '%s' SHOULD COMPARE ITS COLUMN WITH A COLUMN OF THE TABLES BEFORE IT", source.qualifier)
+	}
+	source.leftColumn = left.Path
+	source.rightColumn = strings.TrimPrefix(right.Path, prefix)
+	return nil
+}
+
+// bindTables reads columns of tables of statement and qualifies columns of statement by
+// their tables, items selected by '*' are listed as every column of every table
+func bindTables(statement *query.Statement) ([]*joinSource, error) {
+	tables := []query.Join{{Table: statement.Table, Alias: statement.TableAlias}}
+	tables = append(tables, statement.Joins...)
+
+	var sources []*joinSource
+	for i, table := range tables {
+		tablePieces, err := splitTableName(table.Table)
+		if err != nil {
+			return nil, err
+		}
+		if len(sources) > 0 && !strings.EqualFold(tablePieces[0], sources[0].tablePieces[0]) {
+			return nil, errors.New("JOINED TABLES SHOULD BE OF THE SAME DATABASE")
+		}
+		source := &joinSource{tablePieces: tablePieces, qualifier: table.Alias, kind: table.Kind}
+		if source.qualifier == "" {
+			source.qualifier = tablePieces[1]
+		}
+		for _, earlier := range sources {
+			if strings.EqualFold(earlier.qualifier, source.qualifier) {
+				return nil, fmt.Errorf("TABLE '%s' IS LISTED TWICE, GIVE IT AN ALIAS", source.qualifier)
+			}
+		}
+		if source.columns, err = readTableColumns(tablePieces); err != nil {
+			return nil, err
+		}
+		sources = append(sources, source)
+		if i > 0 {
+			if err := bindJoin(sources, source, table); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	aliases := make(map[string]bool)
+	for _, item := range statement.Items {
+		if item.Alias != "" {
+			aliases[item.Alias] = true
+		}
+		if err := bindExpression(sources, item.Expression); err != nil {
+			return nil, err
+		}
+	}
+	if statement.Items == nil && !statement.HasAggregates() {
+		for _, source := range sources {
+			for _, columnName := range source.columns {
+				column := &query.Column{Path: source.qualifier + "." + columnName}
+				statement.Items = append(statement.Items, query.SelectItem{Expression: column})
+			}
+		}
+	}
+	if err := bindExpression(sources, statement.Where); err != nil {
+		return nil, err
+	}
+	for i := range statement.GroupBy {
+		if err := bindColumn(sources, &statement.GroupBy[i]); err != nil {
+			return nil, err
+		}
+	}
+	for _, item := range statement.OrderBy {
+		// order items naming a listed item are left to the planner
+		if column, ok := item.Expression.(*query.Column); ok && aliases[column.Path] {
+			continue
+		}
+		if err := bindExpression(sources, item.Expression); err != nil {
+			return nil, err
+		}
+	}
+	return sources, nil
+}
+
+// joinRows reads rows of every table and joins them into combined rows, tables are read
+// holding read locks of all of them at once, so that rows are joined as of the same moment
+func joinRows(sources []*joinSource) (*models.DataTable, error) {
+	// tables are locked in the order of their keys, as backups do, a table joined twice is locked once
+	tablesByKey := make(map[string][]string)
+	var keys []string
+	for _, source := range sources {
+		key := tableKey(source.tablePieces)
+		if _, ok := tablesByKey[key]; !ok {
+			tablesByKey[key] = source.tablePieces
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		lock := tableLockFor(tablesByKey[key])
+		if err := lock.RLock(); err != nil {
+			return nil, err
+		}
+		defer lock.RUnlock()
+	}
+
+	tables := make([]*models.DataTable, len(sources))
+	for i, source := range sources {
+		tbl, err := loadTableFile(source.tablePieces)
+		if err != nil {
+			return nil, err
+		}
+		tables[i] = tbl
+	}
+
+	rowData := func(record models.DataRecord) models.DataColumn {
+		data := make(map[string]interface{}, len(record.Columns))
+		for columnName, column := range record.Columns {
+			data[columnName] = column.ColumnData
+		}
+		return models.DataColumn{ColumnData: data}
+	}
+
+	joined := &models.DataTable{Records: make(map[string]models.DataRecord), Columns: []string{sources[0].qualifier}}
+	for _, rowID := range tables[0].OrderedRowIDs() {
+		joined.Records[rowID] = models.DataRecord{Columns: map[string]models.DataColumn{sources[0].qualifier: rowData(tables[0].Records[rowID])}}
+		joined.RowIDs = append(joined.RowIDs, rowID)
+	}
+
+	for i, source := range sources[1:] {
+		tbl := tables[i+1]
+		// rows of joined table by key of their join column value, nulls match no row
+		rowsByKey := make(map[string][]string)
+		for _, rowID := range tbl.OrderedRowIDs() {
+			if value, ok := tbl.Records[rowID].Lookup(source.rightColumn); ok && value.ColumnData != nil {
+				key := indexKey(value.ColumnData)
+				rowsByKey[key] = append(rowsByKey[key], rowID)
+			}
+		}
+
+		combined := &models.DataTable{Records: make(map[string]models.DataRecord), Columns: append(append([]string(nil), joined.Columns...), source.qualifier)}
+		combine := func(rowID string, record models.DataRecord, rightRowID string) {
+			combinedRecord := models.DataRecord{Columns: make(map[string]models.DataColumn, len(record.Columns)+1)}
+			for qualifier, column := range record.Columns {
+				combinedRecord.Columns[qualifier] = column
+			}
+			if rightRowID != "" {
+				combinedRecord.Columns[source.qualifier] = rowData(tbl.Records[rightRowID])
+			}
+			combinedRowID := rowID + "+" + rightRowID
+			combined.Records[combinedRowID] = combinedRecord
+			combined.RowIDs = append(combined.RowIDs, combinedRowID)
+		}
+		for _, rowID := range joined.RowIDs {
+			record := joined.Records[rowID]
+			var matches []string
+			if value, ok := record.Lookup(source.leftColumn); ok && value.ColumnData != nil {
+				matches = rowsByKey[indexKey(value.ColumnData)]
+			}
+			for _, rightRowID := range matches {
+				combine(rowID, record, rightRowID)
+			}
+			if len(matches) == 0 && source.kind == query.JoinLeft {
+				combine(rowID, record, "")
+			}
+		}
+		joined = combined
+	}
+	return joined, nil
+}
@@ -0,0 +1,118 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// Locks are kept per database and per table (see databaseKey and tableKey),
+// so that connections served concurrently do not interleave their changes.
+// Table locks also lock table in storage, shared by readers and exclusive for writers,
+// so that other processes honouring storage locks do not change a table being read or written
+
+// keyLock locks a database or table within this process,
+// and a table in storage against other processes
+type keyLock struct {
+	sync.RWMutex
+	// tablePieces of locked table, nil for a database
+	tablePieces []string
+
+	// readers number of read locks held, storage is locked by the first
+	// reader and unlocked by the last one
+	readersMutex  sync.Mutex
+	readers       int
+	unlockStorage func()
+}
+
+var (
+	locksMutex sync.Mutex
+	locks      = make(map[string]*keyLock)
+)
+
+func lockFor(key string) *keyLock {
+	return keyLockFor(key, nil)
+}
+
+// tableLockFor returns lock of table, which also locks table in storage
+func tableLockFor(tablePieces []string) *keyLock {
+	return keyLockFor(tableKey(tablePieces), tablePieces)
+}
+
+func keyLockFor(key string, tablePieces []string) *keyLock {
+	locksMutex.Lock()
+	defer locksMutex.Unlock()
+
+	lock, ok := locks[key]
+	if !ok {
+		lock = &keyLock{tablePieces: tablePieces}
+		locks[key] = lock
+	}
+	return lock
+}
+
+// lockStorage locks table in storage
+func (lock *keyLock) lockStorage(exclusive bool) error {
+	lock.unlockStorage = nil
+	if lock.tablePieces == nil {
+		return nil
+	}
+	unlock, err := storage.LockTable(lock.tablePieces[0], lock.tablePieces[1], exclusive)
+	if err != nil {
+		fmt.Printf("Error while locking table: (%v)\n", err)
+		return errors.New(dbEngineError)
+	}
+	lock.unlockStorage = unlock
+	return nil
+}
+
+func (lock *keyLock) releaseStorage() {
+	if lock.unlockStorage != nil {
+		lock.unlockStorage()
+		lock.unlockStorage = nil
+	}
+}
+
+// Lock locks for writing, nothing is left locked when table cannot be locked in storage
+func (lock *keyLock) Lock() error {
+	lock.RWMutex.Lock()
+	if err := lock.lockStorage(true); err != nil {
+		lock.RWMutex.Unlock()
+		return err
+	}
+	return nil
+}
+
+// Unlock unlocks for writing
+func (lock *keyLock) Unlock() {
+	lock.releaseStorage()
+	lock.RWMutex.Unlock()
+}
+
+// RLock locks for reading, nothing is left locked when table cannot be locked in storage
+func (lock *keyLock) RLock() error {
+	lock.RWMutex.RLock()
+
+	lock.readersMutex.Lock()
+	defer lock.readersMutex.Unlock()
+	if lock.readers == 0 {
+		if err := lock.lockStorage(false); err != nil {
+			lock.RWMutex.RUnlock()
+			return err
+		}
+	}
+	lock.readers++
+	return nil
+}
+
+// RUnlock unlocks for reading
+func (lock *keyLock) RUnlock() {
+	lock.readersMutex.Lock()
+	lock.readers--
+	if lock.readers == 0 {
+		lock.releaseStorage()
+	}
+	lock.readersMutex.Unlock()
+
+	lock.RWMutex.RUnlock()
+}
@@ -0,0 +1,278 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Memory storage keeps databases in memory only, they are lost when the server stops.
+// It is meant for short-lived servers, e.g. in tests
+
+type memoryTable struct {
+	records []byte
+	schema  []byte
+	indexes []byte
+	// version number of changes made to records
+	version    uint64
+	generation uint64
+}
+
+type memoryStorage struct {
+	mutex     sync.RWMutex
+	databases map[string]map[string]*memoryTable
+}
+
+// NewMemoryStorage returns storage keeping databases in memory
+func NewMemoryStorage() Storage {
+	return &memoryStorage{databases: make(map[string]map[string]*memoryTable)}
+}
+
+// table returns table stored in memory, caller holds the storage mutex
+func (ms *memoryStorage) table(dbName string, tableName string) (*memoryTable, error) {
+	database, ok := ms.databases[strings.ToUpper(dbName)]
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+	table, ok := database[strings.ToUpper(tableName)]
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+	return table, nil
+}
+
+// Open has nothing to take over, memory storage is owned by this process only
+func (ms *memoryStorage) Open() error {
+	return nil
+}
+
+// Recover has nothing to recover, memory storage starts empty
+func (ms *memoryStorage) Recover() error {
+	return nil
+}
+
+func (ms *memoryStorage) ListDatabases() ([]string, error) {
+	ms.mutex.RLock()
+	defer ms.mutex.RUnlock()
+
+	dbNames := make([]string, 0, len(ms.databases))
+	for dbName := range ms.databases {
+		dbNames = append(dbNames, dbName)
+	}
+	sort.Strings(dbNames)
+	return dbNames, nil
+}
+
+func (ms *memoryStorage) DatabaseExists(dbName string) (bool, error) {
+	ms.mutex.RLock()
+	defer ms.mutex.RUnlock()
+
+	_, ok := ms.databases[strings.ToUpper(dbName)]
+	return ok, nil
+}
+
+func (ms *memoryStorage) CreateDatabase(dbName string) error {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+
+	if _, ok := ms.databases[strings.ToUpper(dbName)]; ok {
+		return os.ErrExist
+	}
+	ms.databases[strings.ToUpper(dbName)] = make(map[string]*memoryTable)
+	return nil
+}
+
+func (ms *memoryStorage) DeleteDatabase(dbName string) error {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+
+	database, ok := ms.databases[strings.ToUpper(dbName)]
+	if !ok {
+		return os.ErrNotExist
+	}
+	if len(database) > 0 {
+		return errors.New("database has tables")
+	}
+	delete(ms.databases, strings.ToUpper(dbName))
+	return nil
+}
+
+func (ms *memoryStorage) ListTables(dbName string) ([]string, error) {
+	ms.mutex.RLock()
+	defer ms.mutex.RUnlock()
+
+	database, ok := ms.databases[strings.ToUpper(dbName)]
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+	tableNames := make([]string, 0, len(database))
+	for tableName := range database {
+		tableNames = append(tableNames, tableName)
+	}
+	sort.Strings(tableNames)
+	return tableNames, nil
+}
+
+func (ms *memoryStorage) CreateTable(dbName string, tableName string, schema []byte, archiver ChangeArchiver) error {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+
+	database, ok := ms.databases[strings.ToUpper(dbName)]
+	if !ok {
+		return os.ErrNotExist
+	}
+	if _, ok := database[strings.ToUpper(tableName)]; ok {
+		return os.ErrExist
+	}
+	if err := archiveChange(archiver); err != nil {
+		return err
+	}
+	database[strings.ToUpper(tableName)] = &memoryTable{schema: schema}
+	return nil
+}
+
+func (ms *memoryStorage) DeleteTable(dbName string, tableName string, archiver ChangeArchiver) error {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+
+	if _, err := ms.table(dbName, tableName); err != nil {
+		return err
+	}
+	if err := archiveChange(archiver); err != nil {
+		return err
+	}
+	delete(ms.databases[strings.ToUpper(dbName)], strings.ToUpper(tableName))
+	return nil
+}
+
+// LockTable has nothing to lock, no other process can access memory storage
+func (ms *memoryStorage) LockTable(dbName string, tableName string, exclusive bool) (func(), error) {
+	return func() {}, nil
+}
+
+func (ms *memoryStorage) StatTable(dbName string, tableName string) (TableState, error) {
+	ms.mutex.RLock()
+	defer ms.mutex.RUnlock()
+
+	table, err := ms.table(dbName, tableName)
+	if err != nil {
+		return TableState{}, err
+	}
+	return TableState{
+		Size:       int64(len(table.records)),
+		Version:    fmt.Sprint(table.version),
+		Generation: table.generation,
+	}, nil
+}
+
+func (ms *memoryStorage) ReadRecords(dbName string, tableName string) ([]byte, error) {
+	return ms.ReadRecordsAt(dbName, tableName, 0, -1)
+}
+
+func (ms *memoryStorage) ReadRecordsAt(dbName string, tableName string, offset int64, length int64) ([]byte, error) {
+	ms.mutex.RLock()
+	defer ms.mutex.RUnlock()
+
+	table, err := ms.table(dbName, tableName)
+	if err != nil {
+		return nil, err
+	}
+	end := int64(len(table.records))
+	if length >= 0 {
+		end = offset + length
+	}
+	if offset < 0 || offset > end || end > int64(len(table.records)) {
+		return nil, errors.New("read beyond the end of records")
+	}
+	// records are only appended or replaced, so callers can share them
+	return table.records[offset:end:end], nil
+}
+
+func (ms *memoryStorage) AppendRecord(dbName string, tableName string, record []byte, schema []byte, archiver ChangeArchiver) (int64, error) {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+
+	table, err := ms.table(dbName, tableName)
+	if err != nil {
+		return 0, err
+	}
+	if err := archiveChange(archiver); err != nil {
+		return 0, err
+	}
+	offset := int64(len(table.records))
+	table.records = append(table.records, record...)
+	if schema != nil {
+		table.schema = schema
+	}
+	table.version++
+	return offset, nil
+}
+
+func (ms *memoryStorage) RewriteRecords(dbName string, tableName string, records []byte, schema []byte, archiver ChangeArchiver) error {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+
+	table, err := ms.table(dbName, tableName)
+	if err != nil {
+		return err
+	}
+	if err := archiveChange(archiver); err != nil {
+		return err
+	}
+	table.records = append([]byte(nil), records...)
+	if schema != nil {
+		table.schema = schema
+	}
+	table.version++
+	table.generation++
+	return nil
+}
+
+func (ms *memoryStorage) ReadSchema(dbName string, tableName string) ([]byte, error) {
+	ms.mutex.RLock()
+	defer ms.mutex.RUnlock()
+
+	table, err := ms.table(dbName, tableName)
+	if err != nil {
+		return nil, nil
+	}
+	return table.schema, nil
+}
+
+func (ms *memoryStorage) WriteSchema(dbName string, tableName string, schema []byte) error {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+
+	table, err := ms.table(dbName, tableName)
+	if err != nil {
+		return err
+	}
+	table.schema = schema
+	return nil
+}
+
+func (ms *memoryStorage) ReadIndexes(dbName string, tableName string) ([]byte, error) {
+	ms.mutex.RLock()
+	defer ms.mutex.RUnlock()
+
+	table, err := ms.table(dbName, tableName)
+	if err != nil {
+		return nil, nil
+	}
+	return table.indexes, nil
+}
+
+func (ms *memoryStorage) WriteIndexes(dbName string, tableName string, indexes []byte) error {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+
+	table, err := ms.table(dbName, tableName)
+	if err != nil {
+		return err
+	}
+	table.indexes = indexes
+	return nil
+}
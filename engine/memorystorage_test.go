@@ -0,0 +1,136 @@
+package engine
+
+import "testing"
+
+// useMemoryStorage runs the engine on a new memory storage with empty caches and
+// the change log disabled, settings of the engine are put back when test ends
+func useMemoryStorage(t *testing.T) {
+	previousStorage := storage
+	previousBackupDir := backupDir
+	previousDir, previousSegmentSize, previousSegments := changeLogDir, changeLogSegmentSize, changeLogSegments
+	resetEngineState := func() {
+		SetTableCacheSize(0)
+		SetTableCacheSize(defaultTableCacheSize)
+		indexCache.Lock()
+		indexCache.entries = make(map[string]*cachedIndexes)
+		indexCache.Unlock()
+		changeLogsMutex.Lock()
+		for _, log := range changeLogs {
+			if log.segment != nil {
+				log.segment.Close()
+			}
+		}
+		changeLogs = make(map[string]*changeLog)
+		changeLogsMutex.Unlock()
+	}
+
+	SetStorage(NewMemoryStorage())
+	SetChangeLog("", 0, 0)
+	resetEngineState()
+	t.Cleanup(func() {
+		resetEngineState()
+		SetStorage(previousStorage)
+		SetBackupDir(previousBackupDir)
+		SetChangeLog(previousDir, previousSegmentSize, previousSegments)
+	})
+}
+
+// execute runs command with payload through a new engine
+func execute(message string, payload string) (string, error) {
+	db := &DBEngine{}
+	if err := db.MakeCommand(message, []byte(payload)); err != nil {
+		return "", err
+	}
+	return db.ExecuteCommand()
+}
+
+// mustExecute runs command with payload, failing test when command fails
+func mustExecute(t *testing.T, message string, payload string) string {
+	t.Helper()
+	output, err := execute(message, payload)
+	if err != nil {
+		t.Fatalf("%s failed: %v", message, err)
+	}
+	return output
+}
+
+func TestMemoryStorageCommands(t *testing.T) {
+	useMemoryStorage(t)
+	mustExecute(t, "create-db d", "")
+	mustExecute(t, "create-table d:t --row-id sequence", "")
+
+	tests := []struct {
+		message string
+		payload string
+		output  string
+		err     string
+	}{
+		{message: "write-table d:t", payload: "name: ann\nage: 30\n", output: "TABLE 'd:t' WRITTEN. ROW-ID: row_id_1"},
+		{message: "write-table d:t", payload: "name: bob\nage: 25\n", output: "TABLE 'd:t' WRITTEN. ROW-ID: row_id_2"},
+		{message: "write-table d:t", payload: "name: cid\nage: 35\n", output: "TABLE 'd:t' WRITTEN. ROW-ID: row_id_3"},
+		{message: "write-table d:t", payload: "name: dan\ncity: pune\n", err: "INVALID TABLE-DATA, UNKNOWN COLUMN 'city'"},
+		{message: "read-table d:t", output: "name|age\nann|30\nbob|25\ncid|35"},
+		{message: "read-row d:t row_id_2", output: "name|age\nbob|25"},
+		{message: "filter d:t age > 26", output: "name|age\nann|30\ncid|35"},
+		{message: "filter d:t name = ann", output: "name|age\nann|30"},
+		{message: "sort d:t age desc", output: "name|age\ncid|35\nann|30\nbob|25"},
+		{message: "update-row d:t row_id_2", payload: "name: bob\nage: 40\n", output: "ROW 'row_id_2' UPDATED IN TABLE 'd:t'."},
+		{message: "read-row d:t row_id_2", output: "name|age\nbob|40"},
+		{message: "sort d:t age", output: "name|age\nann|30\ncid|35\nbob|40"},
+		{message: "delete-row d:t row_id_1", output: "ROW 'row_id_1' DELETED FROM TABLE 'd:t'."},
+		{message: "read-row d:t row_id_1", err: "ROW 'row_id_1' DOES NOT EXISTS"},
+		{message: "read-table d:t", output: "name|age\nbob|40\ncid|35"},
+		// row-ids are not reused after a delete
+		{message: "write-table d:t", payload: "name: eve\nage: 20\n", output: "TABLE 'd:t' WRITTEN. ROW-ID: row_id_4"},
+		{message: "sort d:t name desc", output: "name|age\neve|20\ncid|35\nbob|40"},
+		{message: "list-tables d", output: "T"},
+		{message: "delete-db d", err: "CANNOT DELETE DATABASE, IT HAS TABLES"},
+		{message: "delete-table d:t", output: "TABLE 'd:t' deleted."},
+		{message: "read-table d:t", err: "TABLE DOES NOT EXISTS"},
+		{message: "delete-db d", output: "DB 'd' deleted."},
+		{message: "list-dbs", output: "NO DATABASES EXIST"},
+	}
+	for _, test := range tests {
+		output, err := execute(test.message, test.payload)
+		if test.err != "" {
+			if err == nil || err.Error() != test.err {
+				t.Errorf("%s returned error %v, expected %q", test.message, err, test.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s failed: %v", test.message, err)
+			continue
+		}
+		if output != test.output {
+			t.Errorf("%s = %q, expected %q", test.message, output, test.output)
+		}
+	}
+}
+
+func TestMemoryStorageIndexedFilter(t *testing.T) {
+	useMemoryStorage(t)
+	mustExecute(t, "create-db d", "")
+	mustExecute(t, "create-table d:t --row-id sequence", "")
+	for _, payload := range []string{"name: ann\nage: 30\n", "name: bob\nage: 25\n", "name: cid\nage: 35\n"} {
+		mustExecute(t, "write-table d:t", payload)
+	}
+	mustExecute(t, "create-index d:t age ordered", "")
+	mustExecute(t, "write-table d:t", "name: dan\nage: 28\n")
+	mustExecute(t, "update-row d:t row_id_1", "name: ann\nage: 24\n")
+
+	tests := []struct {
+		message string
+		output  string
+	}{
+		{"filter d:t age > 26", "name|age\ncid|35\ndan|28"},
+		{"filter d:t age = 24", "name|age\nann|24"},
+		{"filter d:t age = 30", "name|age"},
+		{"sort d:t age", "name|age\nann|24\nbob|25\ndan|28\ncid|35"},
+	}
+	for _, test := range tests {
+		if output := mustExecute(t, test.message, ""); output != test.output {
+			t.Errorf("%s = %q, expected %q", test.message, output, test.output)
+		}
+	}
+}
@@ -8,9 +8,7 @@ import (
 )
 
 const (
-	dbEngineError   = "DB ENGINE ERROR"
-	dbFileSuffix    = ".db"
-	tableFileSuffix = ".tbl"
+	dbEngineError = "DB ENGINE ERROR"
 )
 
 var (
@@ -26,30 +24,66 @@ var (
 		"WRITE-TABLE",
 		"FILTER",
 		"SORT",
+		"AGGREGATE",
+		"SELECT",
+		"EXPLAIN",
+		"READ-ROW",
+		"UPDATE-ROW",
+		"DELETE-ROW",
+		"ALTER-TABLE",
+		"COMPACT-TABLE",
+		"CREATE-INDEX",
+		"DROP-INDEX",
+		"LIST-INDEXES",
+		"CACHE-STATS",
+		"SHOW-CONFIG",
+		"BACKUP-DB",
+		"RESTORE-DB",
+		"LIST-CHANGES",
 	}
 	cmdArguments = map[string]string{
-		"CREATE-DB":    "1",
-		"LIST-DBS":     "0",
-		"DELETE-DB":    "1",
-		"USE-DB":       "1",
-		"CREATE-TABLE": "1",
-		"DELETE-TABLE": "1",
-		"LIST-TABLES":  "1",
-		"READ-TABLE":   "1",
-		"WRITE-TABLE":  "2",
-		"FILTER":       "multi",
-		"SORT":         "multi",
+		"CREATE-DB":     "1",
+		"LIST-DBS":      "0",
+		"DELETE-DB":     "1",
+		"USE-DB":        "1",
+		"CREATE-TABLE":  "multi",
+		"DELETE-TABLE":  "1",
+		"LIST-TABLES":   "1",
+		"READ-TABLE":    "multi",
+		"WRITE-TABLE":   "1",
+		"FILTER":        "multi",
+		"SORT":          "multi",
+		"AGGREGATE":     "multi",
+		"SELECT":        "multi",
+		"EXPLAIN":       "multi",
+		"READ-ROW":      "2",
+		"UPDATE-ROW":    "2",
+		"DELETE-ROW":    "2",
+		"ALTER-TABLE":   "multi",
+		"COMPACT-TABLE": "1",
+		"CREATE-INDEX":  "multi",
+		"DROP-INDEX":    "2",
+		"LIST-INDEXES":  "1",
+		"CACHE-STATS":   "0",
+		"SHOW-CONFIG":   "0",
+		"BACKUP-DB":     "2",
+		"RESTORE-DB":    "multi",
+		"LIST-CHANGES":  "multi",
 	}
 )
 
-// DBEngine database engine
+// DBEngine database engine, every client connection gets its own DBEngine
 type DBEngine struct {
 	cmd     string
 	cmdArgs []string
+	// payload document sent along with command, e.g. by write-table
+	payload []byte
+	// message command as sent, for commands parsed beyond splitting on whitespace, e.g. select
+	message string
 }
 
-//MakeCommand forms db Command
-func (db *DBEngine) MakeCommand(message string) error {
+// MakeCommand forms db Command, payload is the document sent along with command
+func (db *DBEngine) MakeCommand(message string, payload []byte) error {
 	if validated, err := db.validateMessage(message); !validated {
 		return err
 	}
@@ -57,9 +91,9 @@ func (db *DBEngine) MakeCommand(message string) error {
 	cmd := strings.Fields(message)
 
 	db.cmd = cmd[0]
-	if len(cmd) > 1 {
-		db.cmdArgs = cmd[1:]
-	}
+	db.cmdArgs = cmd[1:]
+	db.payload = payload
+	db.message = message
 	return nil
 }
 
@@ -86,6 +120,40 @@ func (db *DBEngine) ExecuteCommand() (string, error) {
 		return db.readTable()
 	case "WRITE-TABLE":
 		return db.writeTable()
+	case "FILTER":
+		return db.filterTable()
+	case "SORT":
+		return db.sortTable()
+	case "AGGREGATE":
+		return db.aggregate()
+	case "SELECT", "EXPLAIN":
+		return db.selectRows()
+	case "READ-ROW":
+		return db.readRow()
+	case "UPDATE-ROW":
+		return db.updateRow()
+	case "DELETE-ROW":
+		return db.deleteRow()
+	case "ALTER-TABLE":
+		return db.alterTable()
+	case "COMPACT-TABLE":
+		return db.compactTable()
+	case "CREATE-INDEX":
+		return db.createIndex()
+	case "DROP-INDEX":
+		return db.dropIndex()
+	case "LIST-INDEXES":
+		return db.listIndexes()
+	case "CACHE-STATS":
+		return db.cacheStats()
+	case "SHOW-CONFIG":
+		return db.showConfig()
+	case "BACKUP-DB":
+		return db.backupDatabase()
+	case "RESTORE-DB":
+		return db.restoreDatabase()
+	case "LIST-CHANGES":
+		return db.listChanges()
 
 	default:
 		return "", errors.New("INVALID COMMAND")
@@ -95,6 +163,9 @@ func (db *DBEngine) ExecuteCommand() (string, error) {
 
 func (db *DBEngine) validateMessage(message string) (bool, error) {
 	dbCmd := strings.Fields(message)
+	if len(dbCmd) == 0 {
+		return false, errors.New("NO COMMAND PROVIDED")
+	}
 	// Match command if it is a valid command
 	cmdArgsCount, ok := cmdArguments[strings.ToUpper(dbCmd[0])]
 	if !ok {
@@ -0,0 +1,249 @@
+package engine
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/sushilkm/myYamlDB/models"
+	"gopkg.in/yaml.v2"
+)
+
+// Pagination format of read-table, filter and sort is
+// <command> <db-name>:<table-name> ... [--limit <rows>] [--offset <rows>] [--cursor <cursor>]
+// A page lists at most limit rows, skipping offset rows. When rows are left after the page,
+// output ends with a "CURSOR: <cursor>" line, the same command given --cursor <cursor>
+// returns the next page. Pages list rows of sort by its columns and then by row-id, and rows of
+// read-table and filter by row-id, so every row has its place in the order of pages. Cursor is
+// opaque to clients, it holds the sort column values and row-id of the last row listed and the
+// next page starts strictly after them, so rows written or deleted between pages neither repeat
+// nor skip other rows, a row whose sort columns are updated is listed at its new place.
+// Cursor is rejected by a command listing rows of another table, filter or sort order
+
+// cursorPrefix starts the line holding the cursor of the next page
+const cursorPrefix = "CURSOR: "
+
+type pageRequest struct {
+	limit  int
+	offset int
+	cursor *pageCursor
+}
+
+type pageCursor struct {
+	// Query checksum of the command cursor was returned for
+	Query string `json:"q"`
+	// Keys values of sort columns of the last row listed
+	Keys []cursorKey `json:"k,omitempty"`
+	// After row-id of the last row listed
+	After string `json:"a"`
+	Limit int    `json:"l"`
+}
+
+// cursorKey value of a sort column, as yaml so that it is read back as table data is
+type cursorKey struct {
+	Missing bool   `json:"m,omitempty"`
+	Value   string `json:"v,omitempty"`
+}
+
+// pagePosition place of a row in the order of pages
+type pagePosition struct {
+	values []interface{}
+	found  []bool
+	rowID  string
+}
+
+func encodeCursor(cursor pageCursor) string {
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(text string) (*pageCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(text)
+	if err != nil {
+		return nil, errors.New("INVALID CURSOR")
+	}
+	var cursor pageCursor
+	if err := json.Unmarshal(data, &cursor); err != nil || cursor.Limit <= 0 || cursor.After == "" {
+		return nil, errors.New("INVALID CURSOR")
+	}
+	return &cursor, nil
+}
+
+// rowPosition returns place of row in the order of pages
+func rowPosition(tbl *models.DataTable, keys []sortKey, rowID string) pagePosition {
+	position := pagePosition{values: make([]interface{}, len(keys)), found: make([]bool, len(keys)), rowID: rowID}
+	for i, key := range keys {
+		if column, ok := tbl.Records[rowID].Lookup(key.column); ok {
+			position.values[i], position.found[i] = column.ColumnData, true
+		}
+	}
+	return position
+}
+
+// position returns place of the last row listed before cursor
+func (cursor *pageCursor) position() (pagePosition, error) {
+	position := pagePosition{values: make([]interface{}, len(cursor.Keys)), found: make([]bool, len(cursor.Keys)), rowID: cursor.After}
+	for i, key := range cursor.Keys {
+		if key.Missing {
+			continue
+		}
+		var value interface{}
+		if err := yaml.Unmarshal([]byte(key.Value), &value); err != nil {
+			return pagePosition{}, errors.New("INVALID CURSOR")
+		}
+		position.values[i], position.found[i] = models.NormalizeData(value), true
+	}
+	return position, nil
+}
+
+// comparePositions compares places of rows in the order of pages, rows missing a sort column
+// are ordered first as sort orders them
+func comparePositions(first, second pagePosition, keys []sortKey) int {
+	for i, key := range keys {
+		var result int
+		switch {
+		case !first.found[i] && !second.found[i]:
+		case !first.found[i]:
+			result = -1
+		case !second.found[i]:
+			result = 1
+		default:
+			result = compareValues(first.values[i], second.values[i])
+		}
+		if key.descending {
+			result = -result
+		}
+		if result != 0 {
+			return result
+		}
+	}
+	return strings.Compare(first.rowID, second.rowID)
+}
+
+// parsePage takes pagination flags out of command arguments, nil page when none is given
+func parsePage(args []string) ([]string, *pageRequest, error) {
+	var page *pageRequest
+	var remaining []string
+	for i := 0; i < len(args); i++ {
+		flag := strings.ToLower(args[i])
+		if flag != "--limit" && flag != "--offset" && flag != "--cursor" {
+			remaining = append(remaining, args[i])
+			continue
+		}
+		if i+1 >= len(args) {
+			return nil, nil, fmt.Errorf("NO VALUE PROVIDED FOR '%s'", args[i])
+		}
+		if page == nil {
+			page = &pageRequest{}
+		}
+		value := args[i+1]
+		i++
+
+		switch flag {
+		case "--limit":
+			limit, err := strconv.Atoi(value)
+			if err != nil || limit <= 0 {
+				return nil, nil, errors.New("INVALID LIMIT, SHOULD BE A POSITIVE NUMBER")
+			}
+			page.limit = limit
+		case "--offset":
+			offset, err := strconv.Atoi(value)
+			if err != nil || offset < 0 {
+				return nil, nil, errors.New("INVALID OFFSET, SHOULD BE A NUMBER")
+			}
+			page.offset = offset
+		case "--cursor":
+			cursor, err := decodeCursor(value)
+			if err != nil {
+				return nil, nil, err
+			}
+			page.cursor = cursor
+		}
+	}
+	if page != nil && page.cursor != nil && page.offset > 0 {
+		return nil, nil, errors.New("CANNOT USE --offset ALONG WITH --cursor")
+	}
+	return remaining, page, nil
+}
+
+// queryChecksum identifies command a cursor is returned for, pagination flags excluded
+func (db *DBEngine) queryChecksum() string {
+	query := strings.ToUpper(db.cmd) + " " + strings.Join(db.cmdArgs, " ")
+	return fmt.Sprintf("%08x", crc32.ChecksumIEEE([]byte(query)))
+}
+
+// pageOutput returns string representation of page of table, followed by cursor of
+// the next page when rows are left, whole table when page is nil, keys are the sort
+// columns rows are listed by, nil when rows are not sorted
+func (db *DBEngine) pageOutput(tbl *models.DataTable, keys []sortKey, page *pageRequest) (string, error) {
+	if page == nil {
+		return tbl.ToString(), nil
+	}
+
+	rowIDs := append([]string(nil), tbl.OrderedRowIDs()...)
+	positions := make(map[string]pagePosition, len(rowIDs))
+	for _, rowID := range rowIDs {
+		positions[rowID] = rowPosition(tbl, keys, rowID)
+	}
+	sort.SliceStable(rowIDs, func(i, j int) bool {
+		return comparePositions(positions[rowIDs[i]], positions[rowIDs[j]], keys) < 0
+	})
+
+	start := page.offset
+	limit := page.limit
+	if page.cursor != nil {
+		if page.cursor.Query != db.queryChecksum() || len(page.cursor.Keys) != len(keys) {
+			return "", errors.New("CURSOR DOES NOT MATCH COMMAND")
+		}
+		after, err := page.cursor.position()
+		if err != nil {
+			return "", err
+		}
+		start = sort.Search(len(rowIDs), func(i int) bool {
+			return comparePositions(positions[rowIDs[i]], after, keys) > 0
+		})
+		if limit == 0 {
+			limit = page.cursor.Limit
+		}
+	}
+	if start > len(rowIDs) {
+		start = len(rowIDs)
+	}
+	end := len(rowIDs)
+	if limit > 0 && start+limit < end {
+		end = start + limit
+	}
+
+	pageTable := &models.DataTable{
+		Records: make(map[string]models.DataRecord, end-start),
+		RowIDs:  rowIDs[start:end],
+		Columns: tbl.OrderedColumns(),
+	}
+	for _, rowID := range pageTable.RowIDs {
+		pageTable.Records[rowID] = tbl.Records[rowID]
+	}
+	output := pageTable.ToString()
+	if end < len(rowIDs) && end > start {
+		last := positions[rowIDs[end-1]]
+		cursor := pageCursor{Query: db.queryChecksum(), After: last.rowID, Limit: limit}
+		for i := range keys {
+			if !last.found[i] {
+				cursor.Keys = append(cursor.Keys, cursorKey{Missing: true})
+				continue
+			}
+			value, err := yaml.Marshal(last.values[i])
+			if err != nil {
+				fmt.Printf("Error while writing cursor: (%v)\n", err)
+				return "", errors.New(dbEngineError)
+			}
+			cursor.Keys = append(cursor.Keys, cursorKey{Value: string(value)})
+		}
+		output += "\n" + cursorPrefix + encodeCursor(cursor)
+	}
+	return output, nil
+}
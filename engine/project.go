@@ -0,0 +1,79 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/sushilkm/myYamlDB/models"
+)
+
+// Projection command format is
+// read-table <db-name>:<table-name> --columns <column>[,<column>...]
+// Only listed columns are returned, in listed order, columns can address nested data,
+// e.g. "name,address.city,tags[0]"
+
+// parseColumnList parses comma separated column paths
+func parseColumnList(text string) ([]string, error) {
+	var columns []string
+	listed := make(map[string]bool)
+	for _, column := range strings.Split(text, ",") {
+		column = strings.TrimSpace(column)
+		if err := models.ValidateColumnPath(column); err != nil || column == "" {
+			return nil, fmt.Errorf("INVALID COLUMN '%s'", column)
+		}
+		if listed[column] {
+			return nil, fmt.Errorf("COLUMN '%s' IS LISTED TWICE", column)
+		}
+		listed[column] = true
+		columns = append(columns, column)
+	}
+	return columns, nil
+}
+
+// checkColumnsExist checks columns of a table which has any are addressed by column paths
+func checkColumnsExist(tbl *models.DataTable, columns []string) error {
+	tableColumns := tbl.OrderedColumns()
+	if len(tableColumns) == 0 {
+		return nil
+	}
+	known := make(map[string]bool, len(tableColumns))
+	for _, columnName := range tableColumns {
+		known[columnName] = true
+	}
+	for _, column := range columns {
+		columnName, _ := models.ColumnPathRoot(column)
+		if !known[column] && !known[columnName] {
+			return fmt.Errorf("COLUMN '%s' DOES NOT EXISTS", columnName)
+		}
+	}
+	return nil
+}
+
+// projectTable returns table holding only listed columns,
+// columns of a table which has any must address one of them
+func projectTable(tbl *models.DataTable, columns []string) (*models.DataTable, error) {
+	if err := checkColumnsExist(tbl, columns); err != nil {
+		return nil, err
+	}
+	return tbl.Project(columns), nil
+}
+
+// parseProjection takes "--columns <column-list>" out of command arguments,
+// nil columns when not given
+func parseProjection(args []string) ([]string, []string, error) {
+	for i := 0; i < len(args); i++ {
+		if strings.ToLower(args[i]) != "--columns" {
+			continue
+		}
+		if i+1 >= len(args) {
+			return nil, nil, errors.New("NO COLUMNS PROVIDED")
+		}
+		columns, err := parseColumnList(args[i+1])
+		if err != nil {
+			return nil, nil, err
+		}
+		return append(args[:i:i], args[i+2:]...), columns, nil
+	}
+	return args, nil, nil
+}
@@ -0,0 +1,88 @@
+package engine
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/sushilkm/myYamlDB/models"
+)
+
+func TestParseProjection(t *testing.T) {
+	tests := []struct {
+		args    string
+		rest    []string
+		columns []string
+		err     string
+	}{
+		{args: "d:t", rest: []string{"d:t"}},
+		{args: "d:t --columns name", rest: []string{"d:t"}, columns: []string{"name"}},
+		{args: "d:t --COLUMNS age,name", rest: []string{"d:t"}, columns: []string{"age", "name"}},
+		{args: "d:t --columns name,address.city,tags[0] --page 5", rest: []string{"d:t", "--page", "5"},
+			columns: []string{"name", "address.city", "tags[0]"}},
+		{args: "d:t --columns", err: "NO COLUMNS PROVIDED"},
+		{args: "d:t --columns name,", err: "INVALID COLUMN ''"},
+		{args: "d:t --columns name,,age", err: "INVALID COLUMN ''"},
+		{args: "d:t --columns tags[", err: "INVALID COLUMN 'tags['"},
+		{args: "d:t --columns name,age,name", err: "COLUMN 'name' IS LISTED TWICE"},
+	}
+	for _, test := range tests {
+		rest, columns, err := parseProjection(strings.Fields(test.args))
+		if test.err != "" {
+			if err == nil || err.Error() != test.err {
+				t.Errorf("parseProjection(%q) returned error %v, expected %q", test.args, err, test.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseProjection(%q) failed: %v", test.args, err)
+			continue
+		}
+		if !reflect.DeepEqual(rest, test.rest) || !reflect.DeepEqual(columns, test.columns) {
+			t.Errorf("parseProjection(%q) = %v, %v, expected %v, %v", test.args, rest, columns, test.rest, test.columns)
+		}
+	}
+}
+
+func TestProjectTable(t *testing.T) {
+	tbl, ok := models.ParseYaml([]byte(`
+row_id_1: {name: ann, age: 30, address: {city: pune}, tags: [a, b]}
+row_id_2: {name: bob, age: 25, tags: [c]}
+`))
+	if !ok {
+		t.Fatal("ParseYaml failed")
+	}
+	tests := []struct {
+		columns string
+		output  string
+		err     string
+	}{
+		{columns: "name", output: "name\nann\nbob"},
+		{columns: "age,name", output: "age|name\n30|ann\n25|bob"},
+		{columns: "name,address.city,tags[0]", output: "name|address.city|tags[0]\nann|pune|a\nbob||c"},
+		{columns: "tags[1],address.zip", output: "tags[1]|address.zip\nb|\n|"},
+		{columns: "city", err: "COLUMN 'city' DOES NOT EXISTS"},
+		{columns: "name,city.zip", err: "COLUMN 'city' DOES NOT EXISTS"},
+	}
+	for _, test := range tests {
+		columns, err := parseColumnList(test.columns)
+		if err != nil {
+			t.Errorf("parseColumnList(%q) failed: %v", test.columns, err)
+			continue
+		}
+		projected, err := projectTable(tbl, columns)
+		if test.err != "" {
+			if err == nil || err.Error() != test.err {
+				t.Errorf("projectTable(%q) returned error %v, expected %q", test.columns, err, test.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("projectTable(%q) failed: %v", test.columns, err)
+			continue
+		}
+		if output := projected.ToString(); output != test.output {
+			t.Errorf("projectTable(%q) = %q, expected %q", test.columns, output, test.output)
+		}
+	}
+}
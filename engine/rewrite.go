@@ -0,0 +1,113 @@
+package engine
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Files are never rewritten in place, new content is written to a temporary
+// file in the same directory and flushed to disk, then renamed over the file
+// and the directory is flushed to disk, so readers see either the old or the
+// new content and a crash never leaves a partially rewritten file behind.
+// Files are rewritten while holding the lock of their table
+
+// tempFileMarker marks temporary files, "<file-name>.tmp<random-suffix>"
+const tempFileMarker = ".tmp"
+
+// writeTempFile writes data to a temporary file next to fileName and
+// flushes it to disk, the temporary file is to be renamed over fileName
+func writeTempFile(fileName string, data []byte) (string, error) {
+	return streamTempFile(fileName, func(w io.Writer) error {
+		_, err := w.Write(data)
+		return err
+	})
+}
+
+// streamTempFile writes content streamed by write to a temporary file next to fileName and
+// flushes it to disk, the temporary file is to be renamed over fileName
+func streamTempFile(fileName string, write func(w io.Writer) error) (string, error) {
+	f, err := ioutil.TempFile(filepath.Dir(fileName), filepath.Base(fileName)+tempFileMarker)
+	if err != nil {
+		return "", err
+	}
+	if err := write(f); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return "", err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return "", err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), nil
+}
+
+// removeTempFiles removes temporary files left behind in directory by a crash
+func removeTempFiles(dirName string) error {
+	files, err := ioutil.ReadDir(dirName)
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		if strings.Contains(file.Name(), tempFileMarker) {
+			if err := os.Remove(filepath.Join(dirName, file.Name())); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// syncDir flushes directory entries to disk, making renames durable
+func syncDir(dirName string) error {
+	dir, err := os.Open(dirName)
+	if err != nil {
+		return err
+	}
+	defer dir.Close()
+	return dir.Sync()
+}
+
+// rewriteFiles atomically replaces content of every file, all files are
+// written aside before any of them is moved in place.
+// Files are expected to be in the same directory
+func rewriteFiles(fileNames []string, contents [][]byte) error {
+	tempFileNames := make([]string, 0, len(fileNames))
+	removeWritten := func() {
+		for _, tempFileName := range tempFileNames {
+			os.Remove(tempFileName)
+		}
+	}
+
+	for i, fileName := range fileNames {
+		tempFileName, err := writeTempFile(fileName, contents[i])
+		if err != nil {
+			removeWritten()
+			return err
+		}
+		tempFileNames = append(tempFileNames, tempFileName)
+	}
+	for i, fileName := range fileNames {
+		if err := os.Rename(tempFileNames[i], fileName); err != nil {
+			removeWritten()
+			return err
+		}
+	}
+	if len(fileNames) == 0 {
+		return nil
+	}
+	return syncDir(filepath.Dir(fileNames[0]))
+}
+
+// rewriteFile atomically replaces content of file
+func rewriteFile(fileName string, data []byte) error {
+	return rewriteFiles([]string{fileName}, [][]byte{data})
+}
@@ -0,0 +1,154 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/sushilkm/myYamlDB/common"
+	"github.com/sushilkm/myYamlDB/models"
+)
+
+// Rows are never changed in place, an updated row is appended again
+// with the same row-id and a deleted row is appended with no columns,
+// the last record written for a row-id is the one read back
+
+// maxRowIDAttempts number of row-ids tried before giving up on finding an unused one
+const maxRowIDAttempts = 10
+
+// generateRowID generates a row-id never used before in table,
+// using row-id strategy of table schema, caller holds the lock
+// of table and writes the schema back
+func generateRowID(tablePieces []string, schema *models.TableSchema) (string, error) {
+	strategy := schema.RowID
+	if strategy == "" {
+		strategy = common.DefaultRowIDStrategy
+	}
+	generator, ok := common.GetRowIDGenerator(strategy)
+	if !ok {
+		return "", fmt.Errorf("INVALID ROW-ID STRATEGY '%s'", strategy)
+	}
+
+	cachedTable, err := cachedTableFile(tablePieces)
+	if err != nil {
+		return "", err
+	}
+	usedRowIDs := cachedTable.rowIDs
+
+	for attempt := 0; attempt < maxRowIDAttempts; attempt++ {
+		rowID, err := generator.NextRowID(schema.RowSequence)
+		if err != nil {
+			fmt.Printf("Error while generating row-id: (%v)\n", err)
+			return "", errors.New(dbEngineError)
+		}
+		schema.RowSequence++
+		if !usedRowIDs[rowID] {
+			return rowID, nil
+		}
+	}
+	return "", errors.New("CANNOT GENERATE UNIQUE ROW-ID")
+}
+
+// rowArguments checks row command arguments and returns table-name pieces and row-id
+func (db *DBEngine) rowArguments(argsCount int) ([]string, string, error) {
+	if len(db.cmdArgs) != argsCount {
+		return nil, "", errors.New("INVALID NUMBER OF ARGUMENTS")
+	}
+	tablePieces, err := db.parseTableName()
+	if err != nil {
+		return nil, "", err
+	}
+	return tablePieces, db.cmdArgs[1], nil
+}
+
+// findRow looks up row-id in table and returns its record along with table columns,
+// caller holds the lock of table
+func findRow(tablePieces []string, rowID string) (models.DataRecord, []string, error) {
+	cachedTable, err := cachedTableFile(tablePieces)
+	if err != nil {
+		return models.DataRecord{}, nil, err
+	}
+	record, ok := cachedTable.table.Records[rowID]
+	if !ok {
+		return models.DataRecord{}, nil, fmt.Errorf("ROW '%s' DOES NOT EXISTS", rowID)
+	}
+	return record, cachedTable.table.Columns, nil
+}
+
+func (db *DBEngine) readRow() (string, error) {
+	tablePieces, rowID, err := db.rowArguments(2)
+	if err != nil {
+		return "", err
+	}
+	lock := tableLockFor(tablePieces)
+	if err := lock.RLock(); err != nil {
+		return "", err
+	}
+	defer lock.RUnlock()
+
+	record, columns, err := findRow(tablePieces, rowID)
+	if err != nil {
+		return "", err
+	}
+	schema, err := readTableSchema(tablePieces)
+	if err != nil {
+		return "", err
+	}
+
+	rowTable := models.DataTable{
+		Records: map[string]models.DataRecord{rowID: record},
+		RowIDs:  []string{rowID},
+		Columns: columns,
+	}
+	rowTable.ApplySchema(schema)
+	return rowTable.ToString(), nil
+}
+
+func (db *DBEngine) updateRow() (string, error) {
+	tablePieces, rowID, err := db.rowArguments(2)
+	if err != nil {
+		return "", err
+	}
+	lock := tableLockFor(tablePieces)
+	if err := lock.Lock(); err != nil {
+		return "", err
+	}
+	defer lock.Unlock()
+
+	if _, _, err := findRow(tablePieces, rowID); err != nil {
+		return "", err
+	}
+	schema, err := readTableSchema(tablePieces)
+	if err != nil {
+		return "", err
+	}
+	record, err := buildRecord(tablePieces, schema, db.payload)
+	if err != nil {
+		return "", err
+	}
+	if err := appendRecord(tablePieces, rowID, record, schema); err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf(`ROW '%s' UPDATED IN TABLE '%s:%s'.`, rowID, tablePieces[0], tablePieces[1]), nil
+}
+
+func (db *DBEngine) deleteRow() (string, error) {
+	tablePieces, rowID, err := db.rowArguments(2)
+	if err != nil {
+		return "", err
+	}
+	lock := tableLockFor(tablePieces)
+	if err := lock.Lock(); err != nil {
+		return "", err
+	}
+	defer lock.Unlock()
+
+	if _, _, err := findRow(tablePieces, rowID); err != nil {
+		return "", err
+	}
+	if err := appendRecord(tablePieces, rowID, nil, nil); err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf(`ROW '%s' DELETED FROM TABLE '%s:%s'.`, rowID, tablePieces[0], tablePieces[1]), nil
+}
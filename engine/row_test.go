@@ -0,0 +1,59 @@
+package engine
+
+import "testing"
+
+func TestRowCommands(t *testing.T) {
+	useMemoryStorage(t)
+	mustExecute(t, "create-db d", "")
+	mustExecute(t, "create-table d:t --row-id sequence", "")
+	mustExecute(t, "write-table d:t", "name: ann\nage: 30\n")
+	mustExecute(t, "write-table d:t", "name: bob\nage: 25\n")
+
+	tests := []struct {
+		message string
+		payload string
+		output  string
+		err     string
+	}{
+		{message: "read-row d:t row_id_1", output: "name|age\nann|30"},
+		{message: "read-row D:T row_id_2", output: "name|age\nbob|25"},
+		{message: "read-row d:t row_id_9", err: "ROW 'row_id_9' DOES NOT EXISTS"},
+		{message: "read-row d:t", err: "INVALID NUMBER OF ARGUMENTS"},
+		{message: "read-row d:t row_id_1 row_id_2", err: "INVALID NUMBER OF ARGUMENTS"},
+		{message: "read-row d:u row_id_1", err: "TABLE DOES NOT EXISTS"},
+		{message: "update-row d:t row_id_1", payload: "name: ann\nage: 31\n", output: "ROW 'row_id_1' UPDATED IN TABLE 'd:t'."},
+		{message: "read-row d:t row_id_1", output: "name|age\nann|31"},
+		// an updated row keeps its place in the table
+		{message: "read-table d:t", output: "name|age\nann|31\nbob|25"},
+		// an update replaces every column of the row
+		{message: "update-row d:t row_id_1", payload: "age: 32\n", output: "ROW 'row_id_1' UPDATED IN TABLE 'd:t'."},
+		{message: "read-row d:t row_id_1", output: "name|age\n|32"},
+		{message: "update-row d:t row_id_1", payload: "city: pune\n", err: "INVALID TABLE-DATA, UNKNOWN COLUMN 'city'"},
+		{message: "update-row d:t row_id_9", payload: "name: cid\n", err: "ROW 'row_id_9' DOES NOT EXISTS"},
+		{message: "update-row d:t", payload: "name: cid\n", err: "INVALID NUMBER OF ARGUMENTS"},
+		{message: "delete-row d:t row_id_1", output: "ROW 'row_id_1' DELETED FROM TABLE 'd:t'."},
+		{message: "read-row d:t row_id_1", err: "ROW 'row_id_1' DOES NOT EXISTS"},
+		{message: "delete-row d:t row_id_1", err: "ROW 'row_id_1' DOES NOT EXISTS"},
+		{message: "update-row d:t row_id_1", payload: "name: ann\n", err: "ROW 'row_id_1' DOES NOT EXISTS"},
+		{message: "read-table d:t", output: "name|age\nbob|25"},
+		// row-ids of deleted rows are not given out again
+		{message: "write-table d:t", payload: "name: cid\nage: 35\n", output: "TABLE 'd:t' WRITTEN. ROW-ID: row_id_3"},
+		{message: "delete-row d:t", err: "INVALID NUMBER OF ARGUMENTS"},
+	}
+	for _, test := range tests {
+		output, err := execute(test.message, test.payload)
+		if test.err != "" {
+			if err == nil || err.Error() != test.err {
+				t.Errorf("%s returned error %v, expected %q", test.message, err, test.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s failed: %v", test.message, err)
+			continue
+		}
+		if output != test.output {
+			t.Errorf("%s = %q, expected %q", test.message, output, test.output)
+		}
+	}
+}
@@ -0,0 +1,32 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/sushilkm/myYamlDB/models"
+)
+
+// Every table has a schema stored along with it,
+// schema keeps the declared order of table columns.
+// Schema is accessed while holding the lock of its table
+
+// readTableSchema reads schema of table, tables created
+// before schemas were introduced get an empty schema
+func readTableSchema(tablePieces []string) (*models.TableSchema, error) {
+	schemaData, err := storage.ReadSchema(tablePieces[0], tablePieces[1])
+	if err != nil {
+		fmt.Printf("Error while reading table schema: (%v)\n", err)
+		return nil, errors.New(dbEngineError)
+	}
+
+	if schemaData == nil {
+		return &models.TableSchema{}, nil
+	}
+
+	schema, valid := models.ParseTableSchema(schemaData)
+	if !valid {
+		return nil, errors.New("INVALID TABLE SCHEMA")
+	}
+	return schema, nil
+}
@@ -0,0 +1,522 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/sushilkm/myYamlDB/models"
+	"github.com/sushilkm/myYamlDB/query"
+)
+
+// Select command format is
+// [explain] select <items> from <db-name>:<table-name> [where ...] [group by ...] [order by ...] [limit <rows> [offset <rows>]]
+// see package query for the query language. A statement is parsed, planned and then executed:
+// rows are read through indexes when they serve the where condition, filtered, aggregated
+// when aggregates are listed or rows are grouped, ordered, limited and projected to listed items.
+// Comparisons of null or missing values and of values of different kinds, e.g. a string and
+// a number, do not match. 'explain' lists the steps of the plan instead of executing it
+
+// selectPlan how a statement is executed
+type selectPlan struct {
+	statement   *query.Statement
+	tablePieces []string
+	// sources tables joined, nil when rows of a single table are read without a qualifier
+	sources []*joinSource
+	// filter where condition as filter expression, when indexes may serve it
+	filter filterExpression
+	// functions aggregated, listed items first, nil when rows are not aggregated
+	functions    []aggregateFunction
+	groupColumns []string
+	// orderKeys columns of rows ordered, aggregated rows are ordered by their labels
+	orderKeys []sortKey
+}
+
+// queryColumns returns column paths an expression reads
+func queryColumns(expression query.Expression) []string {
+	switch node := expression.(type) {
+	case *query.Column:
+		return []string{node.Path}
+	case *query.Function:
+		if node.Column != nil {
+			return []string{node.Column.Path}
+		}
+	case *query.Binary:
+		return append(queryColumns(node.Left), queryColumns(node.Right)...)
+	case *query.Not:
+		return queryColumns(node.Operand)
+	case *query.IsNull:
+		return queryColumns(node.Operand)
+	}
+	return nil
+}
+
+// toFilterExpression converts where condition into a filter expression, for finding
+// candidate rows through indexes, ok is false when condition has no such form
+func toFilterExpression(expression query.Expression) (filterExpression, bool) {
+	node, ok := expression.(*query.Binary)
+	if !ok {
+		return nil, false
+	}
+	switch node.Operator {
+	case query.OpOr:
+		left, leftOK := toFilterExpression(node.Left)
+		right, rightOK := toFilterExpression(node.Right)
+		return append(left, right...), leftOK && rightOK
+	case query.OpAnd:
+		left, leftOK := toFilterExpression(node.Left)
+		right, rightOK := toFilterExpression(node.Right)
+		if !leftOK || !rightOK || len(left) != 1 || len(right) != 1 {
+			return nil, false
+		}
+		return filterExpression{append(left[0], right[0]...)}, true
+	case query.OpLike:
+		return nil, false
+	}
+
+	// comparison of a column with a value, the value may be written first
+	flipped := map[string]string{"=": "=", "!=": "!=", "<": ">", "<=": ">=", ">": "<", ">=": "<="}
+	operator := node.Operator
+	column, isColumn := node.Left.(*query.Column)
+	literal, isLiteral := node.Right.(*query.Literal)
+	if !isColumn || !isLiteral {
+		column, isColumn = node.Right.(*query.Column)
+		literal, isLiteral = node.Left.(*query.Literal)
+		operator = flipped[operator]
+	}
+	if !isColumn || !isLiteral || literal.Value == nil {
+		return nil, false
+	}
+	value := models.DataColumn{ColumnData: literal.Value}.String()
+	return filterExpression{{{column: column.Path, operator: operator, value: value}}}, true
+}
+
+// planSelect checks statement and plans how it is executed
+func planSelect(statement *query.Statement) (*selectPlan, error) {
+	tablePieces, err := splitTableName(statement.Table)
+	if err != nil {
+		return nil, err
+	}
+	plan := &selectPlan{statement: statement, tablePieces: tablePieces}
+	if len(statement.Joins) > 0 || statement.TableAlias != "" {
+		if plan.sources, err = bindTables(statement); err != nil {
+			return nil, err
+		}
+	}
+
+	var columns []string
+	for _, item := range statement.Items {
+		columns = append(columns, queryColumns(item.Expression)...)
+	}
+	for _, column := range statement.GroupBy {
+		columns = append(columns, column.Path)
+	}
+	for _, item := range statement.OrderBy {
+		columns = append(columns, queryColumns(item.Expression)...)
+	}
+	columns = append(columns, queryColumns(statement.Where)...)
+	for _, column := range columns {
+		if err := models.ValidateColumnPath(column); err != nil {
+			return nil, fmt.Errorf("INVALID COLUMN '%s'", column)
+		}
+	}
+
+	listed := make(map[string]bool)
+	aliases := make(map[string]query.Expression)
+	for _, item := range statement.Items {
+		if listed[item.Name()] {
+			return nil, fmt.Errorf("COLUMN '%s' IS LISTED TWICE", item.Name())
+		}
+		listed[item.Name()] = true
+		if item.Alias != "" {
+			aliases[item.Alias] = item.Expression
+		}
+	}
+
+	if statement.Where != nil && plan.sources == nil {
+		if expression, ok := toFilterExpression(statement.Where); ok {
+			plan.filter = expression
+		}
+	}
+
+	aggregated := statement.HasAggregates()
+	for _, item := range statement.OrderBy {
+		if _, ok := item.Expression.(*query.Function); ok {
+			aggregated = true
+		}
+	}
+	if !aggregated {
+		for _, item := range statement.OrderBy {
+			column := item.Expression.(*query.Column).Path
+			if expression, ok := aliases[column]; ok {
+				column = expression.String()
+			}
+			plan.orderKeys = append(plan.orderKeys, sortKey{column: column, descending: item.Descending})
+		}
+		return plan, nil
+	}
+
+	if statement.Items == nil {
+		return nil, errors.New("CANNOT SELECT * OF AGGREGATED ROWS, LIST GROUP COLUMNS AND AGGREGATES")
+	}
+	grouped := make(map[string]bool)
+	for _, column := range statement.GroupBy {
+		if grouped[column.Path] {
+			return nil, fmt.Errorf("GROUP COLUMN '%s' IS LISTED TWICE", column.Path)
+		}
+		grouped[column.Path] = true
+		plan.groupColumns = append(plan.groupColumns, column.Path)
+	}
+	aggregates := make(map[string]bool)
+	addFunction := func(function *query.Function) error {
+		if _, err := models.NewAggregator(function.Name); err != nil {
+			return err
+		}
+		fn := aggregateFunction{function: function.Name}
+		if function.Column != nil {
+			fn.column = function.Column.Path
+		} else if function.Name != models.AggregateCount {
+			return fmt.Errorf("INVALID AGGREGATE '%s', ONLY COUNT TAKES '*'", function)
+		}
+		if !aggregates[fn.label()] {
+			aggregates[fn.label()] = true
+			plan.functions = append(plan.functions, fn)
+		}
+		return nil
+	}
+	for _, item := range statement.Items {
+		switch expression := item.Expression.(type) {
+		case *query.Function:
+			if err := addFunction(expression); err != nil {
+				return nil, err
+			}
+		case *query.Column:
+			if !grouped[expression.Path] {
+				return nil, fmt.Errorf("COLUMN '%s' SHOULD BE AGGREGATED OR LISTED IN GROUP BY", expression.Path)
+			}
+		}
+	}
+	for _, item := range statement.OrderBy {
+		expression := item.Expression
+		if column, ok := expression.(*query.Column); ok {
+			if aliased, ok := aliases[column.Path]; ok {
+				expression = aliased
+			} else if !grouped[column.Path] {
+				return nil, fmt.Errorf("CANNOT ORDER AGGREGATED ROWS BY COLUMN '%s' NOT LISTED IN GROUP BY", column.Path)
+			}
+		}
+		if function, ok := expression.(*query.Function); ok {
+			if err := addFunction(function); err != nil {
+				return nil, err
+			}
+		}
+		plan.orderKeys = append(plan.orderKeys, sortKey{column: expression.String(), descending: item.Descending})
+	}
+	return plan, nil
+}
+
+// explain returns steps of plan, caller holds the lock of table
+func (plan *selectPlan) explain() (string, error) {
+	statement := plan.statement
+	steps := [][2]string{}
+	scan := [2]string{"scan", "every row of " + strings.Join(plan.tablePieces, ":")}
+	if plan.filter != nil {
+		indexes, _, served, err := planFilterIndexes(plan.tablePieces, plan.filter)
+		if err != nil {
+			return "", err
+		}
+		if served != nil {
+			var lookups []string
+			for _, condition := range served {
+				lookups = append(lookups, fmt.Sprintf("%s index on %s (%s %s %s)", indexes.index(condition.column).Type,
+					condition.column, condition.column, condition.operator, condition.value))
+			}
+			scan = [2]string{"index", strings.Join(lookups, " or ")}
+		}
+	}
+	steps = append(steps, scan)
+	for _, source := range plan.sources {
+		if source.rightColumn == "" {
+			continue
+		}
+		steps = append(steps, [2]string{"join", fmt.Sprintf("%s join %s as %s on %s = %s.%s, hashing rows of %s",
+			strings.ToLower(source.kind), strings.Join(source.tablePieces, ":"), source.qualifier,
+			source.leftColumn, source.qualifier, source.rightColumn, source.qualifier)})
+	}
+	if statement.Where != nil {
+		steps = append(steps, [2]string{"filter", statement.Where.String()})
+	}
+	if plan.functions != nil {
+		var labels []string
+		for _, fn := range plan.functions {
+			labels = append(labels, fn.label())
+		}
+		detail := strings.Join(labels, ", ")
+		if len(plan.groupColumns) > 0 {
+			detail += " group by " + strings.Join(plan.groupColumns, ", ")
+		}
+		steps = append(steps, [2]string{"aggregate", detail})
+	}
+	if len(plan.orderKeys) > 0 {
+		var keys []string
+		for _, key := range plan.orderKeys {
+			if key.descending {
+				keys = append(keys, key.column+" DESC")
+			} else {
+				keys = append(keys, key.column+" ASC")
+			}
+		}
+		steps = append(steps, [2]string{"sort", strings.Join(keys, ", ")})
+	}
+	if statement.Offset > 0 {
+		steps = append(steps, [2]string{"offset", fmt.Sprint(statement.Offset)})
+	}
+	if statement.Limit >= 0 {
+		steps = append(steps, [2]string{"limit", fmt.Sprint(statement.Limit)})
+	}
+	if statement.Items != nil {
+		var names []string
+		for _, item := range statement.Items {
+			names = append(names, item.Name())
+		}
+		steps = append(steps, [2]string{"project", strings.Join(names, ", ")})
+	}
+
+	planList := "step|detail"
+	for _, step := range steps {
+		planList += "\n" + step[0] + "|" + step[1]
+	}
+	return planList, nil
+}
+
+// matchLike matches value with a LIKE pattern, '%' matches any text and '_' any single character
+func matchLike(value, pattern string) bool {
+	if pattern == "" {
+		return value == ""
+	}
+	switch pattern[0] {
+	case '%':
+		for i := 0; i <= len(value); i++ {
+			if matchLike(value[i:], pattern[1:]) {
+				return true
+			}
+		}
+		return false
+	case '_':
+		if value == "" {
+			return false
+		}
+		_, size := utf8.DecodeRuneInString(value)
+		return matchLike(value[size:], pattern[1:])
+	}
+	return value != "" && value[0] == pattern[0] && matchLike(value[1:], pattern[1:])
+}
+
+// operandValue returns value of a column or literal for record, nil when column is missing
+func operandValue(expression query.Expression, record models.DataRecord) interface{} {
+	switch node := expression.(type) {
+	case *query.Literal:
+		return node.Value
+	case *query.Column:
+		if column, ok := record.Lookup(node.Path); ok {
+			return column.ColumnData
+		}
+	}
+	return nil
+}
+
+// evaluateCondition checks if record matches where condition
+func evaluateCondition(expression query.Expression, record models.DataRecord) bool {
+	switch node := expression.(type) {
+	case *query.Not:
+		return !evaluateCondition(node.Operand, record)
+	case *query.IsNull:
+		return (operandValue(node.Operand, record) == nil) != node.Negated
+	case *query.Binary:
+		switch node.Operator {
+		case query.OpAnd:
+			return evaluateCondition(node.Left, record) && evaluateCondition(node.Right, record)
+		case query.OpOr:
+			return evaluateCondition(node.Left, record) || evaluateCondition(node.Right, record)
+		}
+
+		left, right := operandValue(node.Left, record), operandValue(node.Right, record)
+		if left == nil || right == nil {
+			return false
+		}
+		if node.Operator == query.OpLike {
+			return matchLike(models.DataColumn{ColumnData: left}.String(), models.DataColumn{ColumnData: right}.String())
+		}
+		result, err := models.CompareData(left, right)
+		if err != nil {
+			return false
+		}
+		switch node.Operator {
+		case query.OpEqual:
+			return result == 0
+		case query.OpNotEqual:
+			return result != 0
+		case query.OpLess:
+			return result < 0
+		case query.OpLessEqual:
+			return result <= 0
+		case query.OpGreater:
+			return result > 0
+		case query.OpGreaterEqual:
+			return result >= 0
+		}
+	}
+	return false
+}
+
+// projectItems returns table holding listed items of rows, named as listed,
+// aggregated rows hold items by their labels
+func projectItems(tbl *models.DataTable, items []query.SelectItem) *models.DataTable {
+	projected := &models.DataTable{
+		Records: make(map[string]models.DataRecord, len(tbl.Records)),
+		RowIDs:  append([]string(nil), tbl.OrderedRowIDs()...),
+	}
+	for _, item := range items {
+		projected.Columns = append(projected.Columns, item.Name())
+	}
+	for rowID, record := range tbl.Records {
+		projectedRecord := models.DataRecord{Columns: make(map[string]models.DataColumn, len(items))}
+		for _, item := range items {
+			if value, ok := record.Lookup(item.Expression.String()); ok {
+				projectedRecord.Columns[item.Name()] = value
+			}
+		}
+		projected.Records[rowID] = projectedRecord
+	}
+	return projected
+}
+
+// execute reads rows of table and returns rows listed by statement
+func (plan *selectPlan) execute() (*models.DataTable, error) {
+	if plan.sources != nil {
+		tbl, err := joinRows(plan.sources)
+		if err != nil {
+			return nil, err
+		}
+		return plan.list(tbl)
+	}
+	lock := tableLockFor(plan.tablePieces)
+	if err := lock.RLock(); err != nil {
+		return nil, err
+	}
+	if _, err := storage.StatTable(plan.tablePieces[0], plan.tablePieces[1]); os.IsNotExist(err) {
+		lock.RUnlock()
+		return nil, errors.New("TABLE DOES NOT EXISTS")
+	}
+	var tbl *models.DataTable
+	var err error
+	if plan.filter != nil {
+		tbl, err = loadFilterCandidates(plan.tablePieces, plan.filter)
+	}
+	if err == nil && tbl == nil {
+		tbl, err = loadTableFile(plan.tablePieces)
+	}
+	lock.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+	return plan.list(tbl)
+}
+
+// list filters, aggregates, orders, limits and projects rows read
+func (plan *selectPlan) list(tbl *models.DataTable) (*models.DataTable, error) {
+	statement := plan.statement
+	var columns []string
+	for _, item := range statement.Items {
+		columns = append(columns, queryColumns(item.Expression)...)
+	}
+	columns = append(columns, plan.groupColumns...)
+	columns = append(columns, queryColumns(statement.Where)...)
+	if plan.functions == nil {
+		for _, key := range plan.orderKeys {
+			columns = append(columns, key.column)
+		}
+	} else {
+		for _, fn := range plan.functions {
+			if fn.column != "" {
+				columns = append(columns, fn.column)
+			}
+		}
+	}
+	if err := checkColumnsExist(tbl, columns); err != nil {
+		return nil, err
+	}
+
+	if statement.Where != nil {
+		var rowIDs []string
+		for _, rowID := range tbl.OrderedRowIDs() {
+			if evaluateCondition(statement.Where, tbl.Records[rowID]) {
+				rowIDs = append(rowIDs, rowID)
+			} else {
+				delete(tbl.Records, rowID)
+			}
+		}
+		tbl.RowIDs = rowIDs
+	}
+	if plan.functions != nil {
+		var err error
+		if tbl, err = aggregateTable(tbl, plan.functions, plan.groupColumns); err != nil {
+			return nil, err
+		}
+	}
+	if len(plan.orderKeys) > 0 {
+		tbl.RowIDs = sortRowIDs(tbl, plan.orderKeys, nil)
+	}
+
+	rowIDs := tbl.OrderedRowIDs()
+	start, end := statement.Offset, len(rowIDs)
+	if start > end {
+		start = end
+	}
+	if statement.Limit >= 0 && start+statement.Limit < end {
+		end = start + statement.Limit
+	}
+	listed := &models.DataTable{
+		Records: make(map[string]models.DataRecord, end-start),
+		RowIDs:  rowIDs[start:end],
+		Columns: tbl.OrderedColumns(),
+	}
+	for _, rowID := range listed.RowIDs {
+		listed.Records[rowID] = tbl.Records[rowID]
+	}
+
+	if statement.Items == nil {
+		return listed, nil
+	}
+	return projectItems(listed, statement.Items), nil
+}
+
+func (db *DBEngine) selectRows() (string, error) {
+	statement, err := query.Parse(db.message)
+	if err != nil {
+		return "", err
+	}
+	plan, err := planSelect(statement)
+	if err != nil {
+		return "", err
+	}
+
+	if statement.Explain {
+		lock := tableLockFor(plan.tablePieces)
+		if err := lock.RLock(); err != nil {
+			return "", err
+		}
+		defer lock.RUnlock()
+		if _, err := storage.StatTable(plan.tablePieces[0], plan.tablePieces[1]); os.IsNotExist(err) {
+			return "", errors.New("TABLE DOES NOT EXISTS")
+		}
+		return plan.explain()
+	}
+	tbl, err := plan.execute()
+	if err != nil {
+		return "", err
+	}
+	return tbl.ToString(), nil
+}
@@ -0,0 +1,229 @@
+package engine
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/sushilkm/myYamlDB/models"
+	"github.com/sushilkm/myYamlDB/query"
+)
+
+func TestMatchLike(t *testing.T) {
+	tests := []struct {
+		value   string
+		pattern string
+		matches bool
+	}{
+		{"", "", true},
+		{"", "%", true},
+		{"", "_", false},
+		{"ann", "ann", true},
+		{"ann", "an", false},
+		{"ann", "Ann", false},
+		{"ann", "a%", true},
+		{"ann", "%n", true},
+		{"ann", "%nn%", true},
+		{"ann", "a_n", true},
+		{"ann", "a_", false},
+		{"ann", "___", true},
+		{"ann", "%%%", true},
+		{"anne", "a%n", false},
+		{"anne", "a%n%", true},
+		{"pune, india", "%, %", true},
+		{"zoë", "zo_", true},
+		{"zoë", "zo__", false},
+		{"100%", "100%", true},
+	}
+	for _, test := range tests {
+		if matches := matchLike(test.value, test.pattern); matches != test.matches {
+			t.Errorf("matchLike(%q, %q) = %v, expected %v", test.value, test.pattern, matches, test.matches)
+		}
+	}
+}
+
+func TestEvaluateCondition(t *testing.T) {
+	record := models.DataRecord{Columns: map[string]models.DataColumn{
+		"name":    {ColumnData: "ann"},
+		"age":     {ColumnData: 30},
+		"score":   {ColumnData: 2.5},
+		"active":  {ColumnData: true},
+		"nothing": {ColumnData: nil},
+		"address": {ColumnData: map[string]interface{}{"city": "pune"}},
+		"tags":    {ColumnData: []interface{}{"a", "b"}},
+	}}
+	tests := []struct {
+		where   string
+		matches bool
+	}{
+		{"name = 'ann'", true},
+		{"name = 'bob'", false},
+		{"name != 'bob'", true},
+		{"name <> 'ann'", false},
+		{"age = 30", true},
+		{"age = 30.0", true},
+		{"age > 29 AND age < 31", true},
+		{"age >= 30 AND age <= 30", true},
+		{"30 <= age", true},
+		{"age > 30 OR score < 3", true},
+		{"age > 30 OR score > 3", false},
+		{"NOT age > 30", true},
+		{"NOT (age > 29 AND name = 'ann')", false},
+		{"active = TRUE", true},
+		{"active = FALSE", false},
+		{"name LIKE 'a%'", true},
+		{"name LIKE 'A%'", false},
+		{"name NOT LIKE 'b%'", true},
+		{"age LIKE '3_'", true},
+		{"address.city = 'pune'", true},
+		{"address.city LIKE 'p%e'", true},
+		{"tags[1] = 'b'", true},
+		{"tags[2] IS NULL", true},
+		{"address.zip IS NULL", true},
+		{"address.city IS NOT NULL", true},
+		{"nothing IS NULL", true},
+		{"missing IS NULL", true},
+		{"missing IS NOT NULL", false},
+		// comparisons with missing columns or null never match, nor do their negations
+		{"missing = 'x'", false},
+		{"missing != 'x'", false},
+		{"nothing = NULL", false},
+		{"NOT missing = 'x'", true},
+		// values which cannot be compared do not match
+		{"name > 3", false},
+		{"name < 3", false},
+	}
+	for _, test := range tests {
+		statement, err := query.Parse("SELECT * FROM d:t WHERE " + test.where)
+		if err != nil {
+			t.Errorf("Parse(%q) failed: %v", test.where, err)
+			continue
+		}
+		if matches := evaluateCondition(statement.Where, record); matches != test.matches {
+			t.Errorf("evaluateCondition(%q) = %v, expected %v", test.where, matches, test.matches)
+		}
+	}
+}
+
+func TestPlanSelect(t *testing.T) {
+	tests := []struct {
+		text         string
+		functions    []string
+		groupColumns []string
+		orderKeys    []sortKey
+		err          string
+	}{
+		{
+			text:      "SELECT * FROM d:t ORDER BY age DESC, name",
+			orderKeys: []sortKey{{column: "age", descending: true}, {column: "name"}},
+		},
+		{
+			text:      "SELECT name AS n FROM d:t ORDER BY n",
+			orderKeys: []sortKey{{column: "name"}},
+		},
+		{
+			text:      "SELECT count(*) FROM d:t",
+			functions: []string{"count(*)"},
+		},
+		{
+			text:         "SELECT city, count(*), avg(age) FROM d:t GROUP BY city ORDER BY city DESC",
+			functions:    []string{"count(*)", "avg(age)"},
+			groupColumns: []string{"city"},
+			orderKeys:    []sortKey{{column: "city", descending: true}},
+		},
+		{
+			// aggregates ordered by are aggregated even when not listed
+			text:         "SELECT city FROM d:t GROUP BY city ORDER BY max(age), count(*) DESC",
+			functions:    []string{"max(age)", "count(*)"},
+			groupColumns: []string{"city"},
+			orderKeys:    []sortKey{{column: "max(age)"}, {column: "count(*)", descending: true}},
+		},
+		{
+			text:         "SELECT city, sum(total) AS spent FROM d:t GROUP BY city ORDER BY spent DESC",
+			functions:    []string{"sum(total)"},
+			groupColumns: []string{"city"},
+			orderKeys:    []sortKey{{column: "sum(total)", descending: true}},
+		},
+		{
+			text:         "SELECT city, country FROM d:t GROUP BY city, country",
+			groupColumns: []string{"city", "country"},
+		},
+		{text: "SELECT * FROM d:t ORDER BY count(*)", err: "CANNOT SELECT * OF AGGREGATED ROWS, LIST GROUP COLUMNS AND AGGREGATES"},
+		{text: "SELECT name, count(*) FROM d:t", err: "COLUMN 'name' SHOULD BE AGGREGATED OR LISTED IN GROUP BY"},
+		{text: "SELECT sum(*) FROM d:t", err: "INVALID AGGREGATE 'sum(*)', ONLY COUNT TAKES '*'"},
+		{text: "SELECT city FROM d:t GROUP BY city, city", err: "GROUP COLUMN 'city' IS LISTED TWICE"},
+		{text: "SELECT name, age AS name FROM d:t", err: "COLUMN 'name' IS LISTED TWICE"},
+		{
+			text: "SELECT city, count(*) FROM d:t GROUP BY city ORDER BY age",
+			err:  "CANNOT ORDER AGGREGATED ROWS BY COLUMN 'age' NOT LISTED IN GROUP BY",
+		},
+		{text: "SELECT * FROM d:t ORDER BY tags[", err: "INVALID COLUMN 'tags['"},
+		{text: "SELECT * FROM t", err: "INVALID TABLE-NAME"},
+	}
+	for _, test := range tests {
+		statement, err := query.Parse(test.text)
+		if err != nil {
+			t.Errorf("Parse(%q) failed: %v", test.text, err)
+			continue
+		}
+		plan, err := planSelect(statement)
+		if test.err != "" {
+			if err == nil || err.Error() != test.err {
+				t.Errorf("planSelect(%q) returned error %v, expected %q", test.text, err, test.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("planSelect(%q) failed: %v", test.text, err)
+			continue
+		}
+		var functions []string
+		for _, fn := range plan.functions {
+			functions = append(functions, fn.label())
+		}
+		if !reflect.DeepEqual(functions, test.functions) {
+			t.Errorf("planSelect(%q) aggregates %v, expected %v", test.text, functions, test.functions)
+		}
+		if !reflect.DeepEqual(plan.groupColumns, test.groupColumns) {
+			t.Errorf("planSelect(%q) groups by %v, expected %v", test.text, plan.groupColumns, test.groupColumns)
+		}
+		if !reflect.DeepEqual(plan.orderKeys, test.orderKeys) {
+			t.Errorf("planSelect(%q) orders by %v, expected %v", test.text, plan.orderKeys, test.orderKeys)
+		}
+	}
+}
+
+func TestPlanSelectFilter(t *testing.T) {
+	tests := []struct {
+		where    string
+		filter   filterExpression
+		filtered bool
+	}{
+		{"age = 3", filterExpression{{{column: "age", operator: "=", value: "3"}}}, true},
+		{"3 < age", filterExpression{{{column: "age", operator: ">", value: "3"}}}, true},
+		{"age >= 3 AND name = 'ann'", filterExpression{{
+			{column: "age", operator: ">=", value: "3"}, {column: "name", operator: "=", value: "ann"}}}, true},
+		{"age = 3 OR age = 5", filterExpression{
+			{{column: "age", operator: "=", value: "3"}}, {{column: "age", operator: "=", value: "5"}}}, true},
+		// conditions indexes cannot serve are only evaluated on rows
+		{"name LIKE 'a%'", nil, false},
+		{"NOT age = 3", nil, false},
+		{"age IS NULL", nil, false},
+		{"age = NULL", nil, false},
+		{"(age = 3 OR age = 5) AND name = 'ann'", nil, false},
+	}
+	for _, test := range tests {
+		statement, err := query.Parse("SELECT * FROM d:t WHERE " + test.where)
+		if err != nil {
+			t.Errorf("Parse(%q) failed: %v", test.where, err)
+			continue
+		}
+		plan, err := planSelect(statement)
+		if err != nil {
+			t.Errorf("planSelect(%q) failed: %v", test.where, err)
+			continue
+		}
+		if (plan.filter != nil) != test.filtered || !reflect.DeepEqual(plan.filter, test.filter) {
+			t.Errorf("planSelect(%q) filters by %v, expected %v", test.where, plan.filter, test.filter)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/sushilkm/myYamlDB/models"
+)
+
+// Sort command format is
+// sort <db-name>:<table-name> <column> [asc|desc] [, <column> [asc|desc] ...]
+// rows are ordered by first column, ties are broken by the following columns,
+// rows that still tie keep their order in the table,
+// column can address nested data, e.g. "address.city" or "tags[0]"
+
+type sortKey struct {
+	column     string
+	descending bool
+}
+
+func parseSortKeys(args []string) ([]sortKey, error) {
+	if len(args) == 0 {
+		return nil, errors.New("NO SORT COLUMN PROVIDED")
+	}
+
+	var keys []sortKey
+	for _, keyText := range strings.Split(strings.Join(args, " "), ",") {
+		keyPieces := strings.Fields(keyText)
+		if len(keyPieces) == 0 || len(keyPieces) > 2 {
+			return nil, errors.New("INVALID SORT COLUMN, EXPECTED <COLUMN> [ASC|DESC]")
+		}
+
+		if err := models.ValidateColumnPath(keyPieces[0]); err != nil {
+			return nil, fmt.Errorf("INVALID SORT COLUMN '%s'", keyPieces[0])
+		}
+		key := sortKey{column: keyPieces[0]}
+		if len(keyPieces) == 2 {
+			switch strings.ToUpper(keyPieces[1]) {
+			case "ASC":
+			case "DESC":
+				key.descending = true
+			default:
+				return nil, fmt.Errorf("INVALID SORT ORDER '%s', EXPECTED 'ASC' OR 'DESC'", keyPieces[1])
+			}
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// compareColumns compares column values of two records,
+// records missing the column are ordered first, values of different
+// kinds are ordered by their string representation
+func compareColumns(first, second models.DataRecord, column string) int {
+	firstColumn, firstFound := first.Lookup(column)
+	secondColumn, secondFound := second.Lookup(column)
+	switch {
+	case !firstFound && !secondFound:
+		return 0
+	case !firstFound:
+		return -1
+	case !secondFound:
+		return 1
+	}
+
+	return compareValues(firstColumn.ColumnData, secondColumn.ColumnData)
+}
+
+// sortRowIDs sorts rows of table by keys, when ranks of rows in an ordered index
+// on first key are given, rows are compared by their rank on first key
+func sortRowIDs(tbl *models.DataTable, keys []sortKey, ranks map[string]int) []string {
+	rank := func(rowID string) int {
+		if rank, ok := ranks[rowID]; ok {
+			return rank
+		}
+		// rows missing the column are ordered first
+		return -1
+	}
+
+	rowIDs := append([]string(nil), tbl.OrderedRowIDs()...)
+	sort.SliceStable(rowIDs, func(i, j int) bool {
+		first, second := tbl.Records[rowIDs[i]], tbl.Records[rowIDs[j]]
+		for keyIndex, key := range keys {
+			var result int
+			if keyIndex == 0 && ranks != nil {
+				result = rank(rowIDs[i]) - rank(rowIDs[j])
+			} else {
+				result = compareColumns(first, second, key.column)
+			}
+			if key.descending {
+				result = -result
+			}
+			if result != 0 {
+				return result < 0
+			}
+		}
+		return false
+	})
+	return rowIDs
+}
+
+func (db *DBEngine) sortTable() (string, error) {
+	args, page, err := parsePage(db.cmdArgs)
+	if err != nil {
+		return "", err
+	}
+	db.cmdArgs = args
+	if len(db.cmdArgs) < 1 {
+		return "", errors.New("INVALID TABLE-NAME, CANNOT SORT TABLE")
+	}
+
+	keys, err := parseSortKeys(db.cmdArgs[1:])
+	if err != nil {
+		return "", err
+	}
+
+	tablePieces, err := db.parseTableName()
+	if err != nil {
+		return "", err
+	}
+	lock := tableLockFor(tablePieces)
+	if err := lock.RLock(); err != nil {
+		return "", err
+	}
+	tbl, err := loadTableFile(tablePieces)
+	var ranks map[string]int
+	if err == nil {
+		// rank rows by ordered index on first key, when there is one
+		if index := readFreshTableIndexes(tablePieces).index(keys[0].column); index != nil && index.Type == indexOrdered {
+			ranks = index.ranks()
+		}
+	}
+	lock.RUnlock()
+	if err != nil {
+		return "", err
+	}
+
+	tbl.RowIDs = sortRowIDs(tbl, keys, ranks)
+	return db.pageOutput(tbl, keys, page)
+}
@@ -0,0 +1,88 @@
+package engine
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/sushilkm/myYamlDB/models"
+)
+
+func TestParseSortKeys(t *testing.T) {
+	tests := []struct {
+		args string
+		keys []sortKey
+		err  string
+	}{
+		{args: "age", keys: []sortKey{{column: "age"}}},
+		{args: "age DESC", keys: []sortKey{{column: "age", descending: true}}},
+		{args: "age desc, name asc", keys: []sortKey{{column: "age", descending: true}, {column: "name"}}},
+		{args: "age desc,name", keys: []sortKey{{column: "age", descending: true}, {column: "name"}}},
+		{args: "address.city , tags[0] desc", keys: []sortKey{{column: "address.city"}, {column: "tags[0]", descending: true}}},
+		{args: "", err: "NO SORT COLUMN PROVIDED"},
+		{args: "age desc name", err: "INVALID SORT COLUMN, EXPECTED <COLUMN> [ASC|DESC]"},
+		{args: "age,", err: "INVALID SORT COLUMN, EXPECTED <COLUMN> [ASC|DESC]"},
+		{args: "age down", err: "INVALID SORT ORDER 'down', EXPECTED 'ASC' OR 'DESC'"},
+		{args: "tags[", err: "INVALID SORT COLUMN 'tags['"},
+	}
+	for _, test := range tests {
+		keys, err := parseSortKeys(strings.Fields(test.args))
+		if test.err != "" {
+			if err == nil || err.Error() != test.err {
+				t.Errorf("parseSortKeys(%q) returned error %v, expected %q", test.args, err, test.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseSortKeys(%q) failed: %v", test.args, err)
+			continue
+		}
+		if !reflect.DeepEqual(keys, test.keys) {
+			t.Errorf("parseSortKeys(%q) = %v, expected %v", test.args, keys, test.keys)
+		}
+	}
+}
+
+func TestSortRowIDs(t *testing.T) {
+	tbl, ok := models.ParseYaml([]byte(`
+row_id_1: {name: ann, age: 30, city: pune}
+row_id_2: {name: bob, age: 4, city: goa}
+row_id_3: {name: cid, age: 30}
+row_id_4: {name: dan, age: 12.5, city: pune}
+row_id_5: {name: eve, age: x, city: goa}
+`))
+	if !ok {
+		t.Fatal("ParseYaml failed")
+	}
+	tests := []struct {
+		args string
+		// ranks of rows in an ordered index on first key, if any
+		ranks  map[string]int
+		rowIDs []string
+	}{
+		// numbers are ordered as numbers, values of other kinds by their text,
+		// rows missing the column first, rows still tied keep their order in the table
+		{args: "age", rowIDs: []string{"row_id_2", "row_id_4", "row_id_1", "row_id_3", "row_id_5"}},
+		{args: "age desc", rowIDs: []string{"row_id_5", "row_id_1", "row_id_3", "row_id_4", "row_id_2"}},
+		{args: "city", rowIDs: []string{"row_id_3", "row_id_2", "row_id_5", "row_id_1", "row_id_4"}},
+		{args: "city, name desc", rowIDs: []string{"row_id_3", "row_id_5", "row_id_2", "row_id_4", "row_id_1"}},
+		{args: "city desc, age", rowIDs: []string{"row_id_4", "row_id_1", "row_id_2", "row_id_5", "row_id_3"}},
+		{args: "missing", rowIDs: []string{"row_id_1", "row_id_2", "row_id_3", "row_id_4", "row_id_5"}},
+		{
+			// rows are ordered by their rank on first key, rows not ranked first
+			args:   "age, name desc",
+			ranks:  map[string]int{"row_id_1": 0, "row_id_2": 1, "row_id_3": 0, "row_id_4": 2},
+			rowIDs: []string{"row_id_5", "row_id_3", "row_id_1", "row_id_2", "row_id_4"},
+		},
+	}
+	for _, test := range tests {
+		keys, err := parseSortKeys(strings.Fields(test.args))
+		if err != nil {
+			t.Errorf("parseSortKeys(%q) failed: %v", test.args, err)
+			continue
+		}
+		if rowIDs := sortRowIDs(tbl, keys, test.ranks); !reflect.DeepEqual(rowIDs, test.rowIDs) {
+			t.Errorf("sortRowIDs(%q) = %v, expected %v", test.args, rowIDs, test.rowIDs)
+		}
+	}
+}
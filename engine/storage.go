@@ -0,0 +1,154 @@
+package engine
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/sushilkm/myYamlDB/common"
+)
+
+// Storage keeps databases and their tables for the engine. Records of a table
+// are kept as an append-only yaml document, along with table schema and indexes.
+// Database and table names are case-insensitive, missing databases and tables
+// are reported by errors satisfying os.IsNotExist.
+// Engine calls storage holding the lock of the database or table it accesses.
+// Changes of tables made through an archiver are archived by it once they are durable
+// in the write-ahead log of storage and before they are applied, a change failing to be
+// archived is not applied, a nil archiver does not archive the change
+type Storage interface {
+	// Open takes storage over for this process, failing when another process owns it,
+	// it is called once, before Recover
+	Open() error
+	// Recover brings storage back to a consistent state after a crash,
+	// it is called once, before serving clients
+	Recover() error
+
+	ListDatabases() ([]string, error)
+	DatabaseExists(dbName string) (bool, error)
+	CreateDatabase(dbName string) error
+	// DeleteDatabase deletes database, which has no tables
+	DeleteDatabase(dbName string) error
+
+	ListTables(dbName string) ([]string, error)
+	// CreateTable creates table with no records
+	CreateTable(dbName string, tableName string, schema []byte, archiver ChangeArchiver) error
+	// DeleteTable deletes table along with its schema and indexes
+	DeleteTable(dbName string, tableName string, archiver ChangeArchiver) error
+	// LockTable locks table against other processes, shared or exclusive,
+	// until returned unlock is called, a table which does not exist is not locked
+	LockTable(dbName string, tableName string, exclusive bool) (func(), error)
+
+	// StatTable returns state of table records
+	StatTable(dbName string, tableName string) (TableState, error)
+	ReadRecords(dbName string, tableName string) ([]byte, error)
+	// ReadRecordsAt reads length bytes of table records starting at offset,
+	// up to the end of records when length is negative
+	ReadRecordsAt(dbName string, tableName string, offset int64, length int64) ([]byte, error)
+	// AppendRecord appends record to table records and returns offset it was appended at,
+	// schema is replaced along with it when not nil, a crash keeps both or neither
+	AppendRecord(dbName string, tableName string, record []byte, schema []byte, archiver ChangeArchiver) (int64, error)
+	// RewriteRecords replaces table records, schema is replaced along with them when not nil,
+	// readers see either old or new records
+	RewriteRecords(dbName string, tableName string, records []byte, schema []byte, archiver ChangeArchiver) error
+
+	// ReadSchema reads table schema, nil when table has none
+	ReadSchema(dbName string, tableName string) ([]byte, error)
+	WriteSchema(dbName string, tableName string, schema []byte) error
+
+	// ReadIndexes reads table indexes, nil when table has none
+	ReadIndexes(dbName string, tableName string) ([]byte, error)
+	// WriteIndexes replaces table indexes, nil indexes deletes them
+	WriteIndexes(dbName string, tableName string, indexes []byte) error
+}
+
+// ChangeArchiver archives a change of a table to the change log of its database
+type ChangeArchiver interface {
+	// ChangeID identifies the change, storage keeps it in its write-ahead log
+	// so that changes applied again on recovery can be archived
+	ChangeID() string
+	Archive() error
+	// Abort archives that the change failed to be applied after it was archived
+	Abort() error
+}
+
+// archiveChange archives change through archiver, if any
+func archiveChange(archiver ChangeArchiver) error {
+	if archiver == nil {
+		return nil
+	}
+	return archiver.Archive()
+}
+
+// abortChange archives that change failed to be applied, if it was archived
+func abortChange(archiver ChangeArchiver) {
+	if archiver == nil {
+		return
+	}
+	if err := archiver.Abort(); err != nil {
+		fmt.Printf("Error while logging failed change: (%v)\n", err)
+	}
+}
+
+// changeID identifies change made through archiver, empty when there is none
+func changeID(archiver ChangeArchiver) string {
+	if archiver == nil {
+		return ""
+	}
+	return archiver.ChangeID()
+}
+
+// TableState describes table records
+type TableState struct {
+	Size int64
+	// Version changes whenever table records change
+	Version string
+	// Generation changes whenever table records are rewritten through storage
+	Generation uint64
+}
+
+// Storage kinds
+const (
+	StorageFile   = "file"
+	StorageMemory = "memory"
+)
+
+// storage used by the engine, selected at server start
+var storage Storage = NewFileStorage(common.DBLocation)
+
+// NewStorage returns storage of given kind,
+// file storage keeps databases as directories under location
+func NewStorage(kind string, location string) (Storage, error) {
+	switch strings.ToLower(kind) {
+	case StorageFile:
+		return NewFileStorage(location), nil
+	case StorageMemory:
+		return NewMemoryStorage(), nil
+	}
+	return nil, fmt.Errorf("unknown storage '%s', expected '%s' or '%s'", kind, StorageFile, StorageMemory)
+}
+
+// SetStorage sets storage used by the engine, to be called before serving clients
+func SetStorage(newStorage Storage) {
+	storage = newStorage
+}
+
+// OpenStorage takes storage over for this server, to be called before RecoverDatabases
+func OpenStorage() error {
+	return storage.Open()
+}
+
+// RecoverDatabases brings storage back to a consistent state after a crash,
+// to be called before serving clients
+func RecoverDatabases() error {
+	return storage.Recover()
+}
+
+// tableKey identifies table in locks and table cache
+func tableKey(tablePieces []string) string {
+	return "table " + strings.ToUpper(tablePieces[0]) + ":" + strings.ToUpper(tablePieces[1])
+}
+
+// databaseKey identifies database in locks
+func databaseKey(dbName string) string {
+	return "database " + strings.ToUpper(dbName)
+}
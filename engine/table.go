@@ -1,11 +1,10 @@
 package engine
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
-	"io/ioutil"
 	"os"
-	"path/filepath"
 	"strings"
 
 	"github.com/sushilkm/myYamlDB/common"
@@ -19,24 +18,54 @@ import (
 // also reject table-name if it has ":"
 
 func (db *DBEngine) parseTableName() ([]string, error) {
-	tablePieces := strings.Split(db.cmdArgs[0], ":")
+	return splitTableName(db.cmdArgs[0])
+}
+
+// splitTableName splits "<db-name>:<table-name>" into database and table name
+func splitTableName(tableName string) ([]string, error) {
+	tablePieces := strings.Split(tableName, ":")
 	if len(tablePieces) > 2 {
 		return nil, errors.New("INVALID TABLE-NAME, VALID TABLE-NAME SHOULD NOT HAVE ':'. PLEASE REENTER TABLE NAME AS <DB-NAME>:<TABLE-NAME>")
 	}
+	if len(tablePieces) < 2 {
+		return nil, errors.New("INVALID TABLE-NAME")
+	}
 	return tablePieces, nil
 }
 
-func (db *DBEngine) initializeTable() error {
+func (db *DBEngine) initializeTable(schema *models.TableSchema) error {
 	tablePieces, err := db.parseTableName()
 	if err != nil {
 		return err
 	}
-	if len(tablePieces) < 2 {
-		return errors.New("INVALID TABLE-NAME")
+	// tables are not created while their database is backed up
+	dbLock := lockFor(databaseKey(tablePieces[0]))
+	if err := dbLock.RLock(); err != nil {
+		return err
 	}
-	tableFileName := strings.ToUpper(filepath.Join(common.DBLocation, tablePieces[0]+dbFileSuffix, tablePieces[1])) + tableFileSuffix
-	if _, err = os.Stat(tableFileName); os.IsNotExist(err) {
-		return ioutil.WriteFile(tableFileName, []byte("{}\n"), 0644)
+	defer dbLock.RUnlock()
+	lock := tableLockFor(tablePieces)
+	if err := lock.Lock(); err != nil {
+		return err
+	}
+	defer lock.Unlock()
+
+	if _, err = storage.StatTable(tablePieces[0], tablePieces[1]); os.IsNotExist(err) {
+		schemaData, err := schema.ToYaml()
+		if err != nil {
+			fmt.Printf("Error while writing table schema: (%v)\n", err)
+			return errors.New(dbEngineError)
+		}
+		archiver := newChangeArchiver(tablePieces[0], changeEntry{
+			Operation: changeCreateTable,
+			Table:     strings.ToUpper(tablePieces[1]),
+			Schema:    schemaData,
+		})
+		err = storage.CreateTable(tablePieces[0], tablePieces[1], schemaData, archiver)
+		if os.IsNotExist(err) {
+			return errors.New("INVALID DB-NAME, DATABASE DOES NOT EXISTS")
+		}
+		return err
 	}
 	return errors.New("TABLE '" + strings.ToUpper(db.cmdArgs[0]) + "' ALREADY EXISTS")
 }
@@ -46,12 +75,10 @@ func (db *DBEngine) listTables() (string, error) {
 		return "", errors.New("NO DATABASE IS USED")
 	}
 
-	dbLocation := filepath.Join(common.DBLocation, db.cmdArgs[0]) + dbFileSuffix
-	if _, err := os.Stat(dbLocation); os.IsNotExist(err) {
+	tables, err := storage.ListTables(db.cmdArgs[0])
+	if os.IsNotExist(err) {
 		return "", errors.New("INVALID DB-NAME, DATABASE DOES NOT EXISTS")
 	}
-
-	tables, err := ioutil.ReadDir(dbLocation)
 	if err != nil {
 		fmt.Printf("Error while listing tables: (%v)\n", err)
 		return "", errors.New(dbEngineError)
@@ -60,25 +87,67 @@ func (db *DBEngine) listTables() (string, error) {
 		return "NO TABLES EXIST", nil
 	}
 
-	var tableList string
-	for _, table := range tables {
-		if !strings.HasSuffix(table.Name(), tableFileSuffix) {
-			continue
-		}
-		if tableList != "" {
-			tableList += "\n"
+	return strings.ToUpper(strings.Join(tables, "\n")), nil
+}
+
+// Create table command format is
+// create-table <db-name>:<table-name> [--schema] [--row-id sequence|ulid|uuid]
+// with --schema the command payload is a yaml schema document declaring table columns,
+// --row-id takes precedence over row-id strategy declared in schema
+
+func (db *DBEngine) parseCreateTableOptions() (*models.TableSchema, error) {
+	schema := &models.TableSchema{}
+	var rowIDStrategy string
+	options := db.cmdArgs[1:]
+	for i := 0; i < len(options); i++ {
+		switch strings.ToLower(options[i]) {
+		case "--schema":
+			if len(db.payload) == 0 {
+				return nil, errors.New("NO SCHEMA PROVIDED")
+			}
+			declaredSchema, valid := models.ParseTableSchema(db.payload)
+			if !valid {
+				return nil, errors.New("INVALID SCHEMA PROVIDED")
+			}
+			schema = declaredSchema
+		case "--row-id":
+			if i+1 == len(options) {
+				return nil, fmt.Errorf("NO VALUE PROVIDED FOR OPTION '%s'", options[i])
+			}
+			i++
+			rowIDStrategy = options[i]
+		default:
+			return nil, fmt.Errorf("INVALID OPTION '%s'", options[i])
 		}
-		tableList += strings.Trim(table.Name(), tableFileSuffix)
 	}
-	return strings.ToUpper(tableList), nil
+	if err := schema.Validate(); err != nil {
+		return nil, err
+	}
+
+	if rowIDStrategy == "" {
+		rowIDStrategy = schema.RowID
+	}
+	if rowIDStrategy == "" {
+		rowIDStrategy = common.DefaultRowIDStrategy
+	}
+	if _, ok := common.GetRowIDGenerator(strings.ToLower(rowIDStrategy)); !ok {
+		return nil, fmt.Errorf("INVALID ROW-ID STRATEGY '%s'", rowIDStrategy)
+	}
+	schema.RowID = strings.ToLower(rowIDStrategy)
+	schema.RowSequence = 0
+	return schema, nil
 }
 
 func (db *DBEngine) createTable() (string, error) {
-	if len(db.cmdArgs) != 1 {
+	if len(db.cmdArgs) < 1 {
 		return "", errors.New("INVALID TABLE-NAME, CANNOT CREATE TABLE")
 	}
 
-	if err := db.initializeTable(); err != nil {
+	schema, err := db.parseCreateTableOptions()
+	if err != nil {
+		return "", err
+	}
+	if err := db.initializeTable(schema); err != nil {
 		return "", err
 	}
 
@@ -94,135 +163,272 @@ func (db *DBEngine) deleteTable() (string, error) {
 	if err != nil {
 		return "", err
 	}
-	tableFileName := filepath.Join(common.DBLocation, tablePieces[0]+dbFileSuffix, tablePieces[1]) + tableFileSuffix
-	if _, err = os.Stat(tableFileName); os.IsNotExist(err) {
+	// tables are not deleted while their database is backed up
+	dbLock := lockFor(databaseKey(tablePieces[0]))
+	if err := dbLock.RLock(); err != nil {
+		return "", err
+	}
+	defer dbLock.RUnlock()
+	lock := tableLockFor(tablePieces)
+	if err := lock.Lock(); err != nil {
+		return "", err
+	}
+	defer lock.Unlock()
+
+	if _, err = storage.StatTable(tablePieces[0], tablePieces[1]); os.IsNotExist(err) {
 		return "", errors.New("TABLE DOES NOT EXISTS")
 	}
 
-	if err := os.Remove(tableFileName); err != nil {
+	tables.remove(tableKey(tablePieces))
+	archiver := newChangeArchiver(tablePieces[0], changeEntry{Operation: changeDeleteTable, Table: strings.ToUpper(tablePieces[1])})
+	if err := storage.DeleteTable(tablePieces[0], tablePieces[1], archiver); err != nil {
 		return "", err
 	}
 
 	return fmt.Sprintf(`TABLE '%s:%s' deleted.`, tablePieces[0], tablePieces[1]), nil
 }
 
-func (db *DBEngine) readTable() (string, error) {
-	if len(db.cmdArgs) != 1 {
-		return "", errors.New("INVALID TABLE-NAME, CANNOT READ TABLE")
+// Tables used to be created with an empty yaml map "{}" as their first line,
+// yaml parser stops at it and ignores rows appended afterwards, so drop it
+const emptyTableMarker = "{}\n"
+
+func readTableRecords(tablePieces []string) ([]byte, error) {
+	tableData, err := storage.ReadRecords(tablePieces[0], tablePieces[1])
+	if err != nil {
+		return nil, err
 	}
+	return bytes.TrimPrefix(tableData, []byte(emptyTableMarker)), nil
+}
 
+// loadTable reads and parses the table named in first command argument
+func (db *DBEngine) loadTable() (*models.DataTable, error) {
 	tablePieces, err := db.parseTableName()
 	if err != nil {
-		return "", err
-	}
-	tableFileName := filepath.Join(common.DBLocation, tablePieces[0]+dbFileSuffix, tablePieces[1]) + tableFileSuffix
-	if _, err = os.Stat(tableFileName); os.IsNotExist(err) {
-		return "", errors.New("TABLE DOES NOT EXISTS")
+		return nil, err
 	}
-	tableData, _ := ioutil.ReadFile(tableFileName)
-	if tbl, valid := models.ParseYaml(tableData); valid {
-		return tbl.ToString(), nil
+	lock := tableLockFor(tablePieces)
+	if err := lock.RLock(); err != nil {
+		return nil, err
 	}
+	defer lock.RUnlock()
 
-	return "", errors.New("INVALID TABLE DATA")
+	return loadTableFile(tablePieces)
 }
 
-func (db *DBEngine) writeTable() (string, error) {
-	if len(db.cmdArgs) < 1 {
-		return "", errors.New("INVALID TABLE-NAME, CANNOT WRITE TABLE")
+// loadTableFile reads and parses table, caller holds the lock of table file
+func loadTableFile(tablePieces []string) (*models.DataTable, error) {
+	cachedTable, err := cachedTableFile(tablePieces)
+	if err != nil {
+		return nil, err
 	}
-	if len(db.cmdArgs) < 2 {
-		return "", errors.New("INVALID TABLE-DATA, CANNOT WRITE TABLE")
+	tbl := cachedTable.table.Copy()
+
+	schema, err := readTableSchema(tablePieces)
+	if err != nil {
+		return nil, err
 	}
+	tbl.ApplySchema(schema)
+	return tbl, nil
+}
 
-	tablePieces, err := db.parseTableName()
+func (db *DBEngine) readTable() (string, error) {
+	args, page, err := parsePage(db.cmdArgs)
 	if err != nil {
 		return "", err
 	}
-	tableFileName := filepath.Join(common.DBLocation, tablePieces[0]+dbFileSuffix, tablePieces[1]) + tableFileSuffix
-	if _, err = os.Stat(tableFileName); os.IsNotExist(err) {
-		return "", errors.New("TABLE DOES NOT EXISTS")
+	args, columns, err := parseProjection(args)
+	if err != nil {
+		return "", err
 	}
+	if len(args) != 1 {
+		return "", errors.New("INVALID TABLE-NAME, CANNOT READ TABLE")
+	}
+	db.cmdArgs = args
 
-	if db.cmdArgs[1] == "NO-DATA" {
-		return "", errors.New("NO TABLE-DATA PROVIDED")
+	tbl, err := db.loadTable()
+	if err != nil {
+		return "", err
 	}
-	if db.cmdArgs[1] == "INVALID-DATA" {
-		return "", errors.New("INVALID TABLE-DATA PROVIDED")
+	if columns != nil {
+		if tbl, err = projectTable(tbl, columns); err != nil {
+			return "", err
+		}
 	}
-	newData := common.DecodeFileContent(db.cmdArgs[1])
-	// Now compare the columns of new-data to column list of old data
-	// if they do not match then reject the request
+	return db.pageOutput(tbl, nil, page)
+}
 
-	newRecord, valid := models.ParseYamlRecord([]byte(newData))
-	if !valid {
-		return "", errors.New("INVALID TABLE-DATA PROVIDED")
+// buildRecord validates document against table schema
+// and returns document columns in their declared order,
+// schema learns its columns from the table when it does not declare any,
+// caller holds the lock of table and writes the schema back
+func buildRecord(tablePieces []string, schema *models.TableSchema, newData []byte) (yaml.MapSlice, error) {
+	if len(newData) == 0 {
+		return nil, errors.New("NO TABLE-DATA PROVIDED")
 	}
+	// Now compare the columns of new-data to columns declared in table schema
+	// if they do not match then reject the request
 
-	tableData, _ := ioutil.ReadFile(tableFileName)
-	existingTable, valid := models.ParseYaml(tableData)
+	newColumnList, valid := models.ParseYamlColumnNames(newData)
 	if !valid {
-		return "", errors.New("INVALID TABLE DATA IN EXISTING TABLE")
+		return nil, errors.New("INVALID TABLE-DATA PROVIDED")
 	}
 
-	var newColumnList, oldColumnList []string
-
-	for key := range newRecord.Columns {
-		newColumnList = append(newColumnList, key)
+	if len(schema.Columns) == 0 {
+		// tables created before schemas were introduced learn their columns from existing rows,
+		// empty tables learn them from the first document written
+		existingTable, err := cachedTableFile(tablePieces)
+		if err != nil {
+			return nil, err
+		}
+		if len(existingTable.table.Records) > 0 {
+			schema.AddColumns(existingTable.table.Columns...)
+		} else {
+			schema.AddColumns(newColumnList...)
+		}
 	}
 
-	for _, record := range existingTable.Records {
-		for columnName := range record.Columns {
-			oldColumnList = append(oldColumnList, columnName)
+	for _, columnName := range newColumnList {
+		if !schema.HasColumn(columnName) {
+			return nil, fmt.Errorf("INVALID TABLE-DATA, UNKNOWN COLUMN '%s'", columnName)
 		}
-		break
 	}
 
-	//Now compare old and new column lists
+	var document yaml.MapSlice
+	if err := yaml.Unmarshal(newData, &document); err != nil {
+		return nil, errors.New("INVALID TABLE-DATA")
+	}
+	dataMap := make(map[string]interface{})
+	for _, item := range document {
+		dataMap[fmt.Sprint(item.Key)] = item.Value
+	}
 
-	var columnListMatches = true
-	for _, newValue := range newColumnList {
-		var columnFound bool
-		for _, oldValue := range oldColumnList {
-			if newValue == oldValue {
-				columnFound = true
-				break
+	// Keep record columns in their declared order,
+	// missing and null columns get their default value
+	orderedRecord := yaml.MapSlice{}
+	for _, column := range schema.Columns {
+		columnValue := dataMap[column.Name]
+		if columnValue == nil {
+			columnValue = column.Default
+		}
+		if columnValue == nil {
+			if column.Required {
+				return nil, fmt.Errorf("INVALID TABLE-DATA, MISSING REQUIRED COLUMN '%s'", column.Name)
 			}
+			continue
+		}
+		if err := column.CheckData(columnValue); err != nil {
+			return nil, err
 		}
-		if !columnFound {
-			columnListMatches = false
-			break
+		orderedRecord = append(orderedRecord, yaml.MapItem{Key: column.Name, Value: columnValue})
+	}
+	return orderedRecord, nil
+}
+
+// appendRecord appends record to table, a nil record marks row as deleted,
+// schema is written along when not nil, caller holds the lock of table
+func appendRecord(tablePieces []string, rowID string, record yaml.MapSlice, schema *models.TableSchema) error {
+	var recordValue interface{}
+	if record != nil {
+		recordValue = record
+	}
+	recordToBeWritten, err := yaml.Marshal(yaml.MapSlice{{Key: rowID, Value: recordValue}})
+	if err != nil {
+		return errors.New("INVALID TABLE-DATA")
+	}
+
+	var schemaData []byte
+	if schema != nil {
+		if schemaData, err = schema.ToYaml(); err != nil {
+			fmt.Printf("Error while writing table schema: (%v)\n", err)
+			return errors.New(dbEngineError)
 		}
 	}
-	if !columnListMatches {
-		fmt.Printf("OLD-COLUMN (%v)\n", oldColumnList)
-		fmt.Printf("NEW-COLUMN (%v)\n", newColumnList)
-		return "", errors.New("INVALID TABLE-DATA, COLUMNS DON'T MATCH WITH EXISTING TABLE")
+
+	archiver := newChangeArchiver(tablePieces[0], changeEntry{
+		Operation: changeAppend,
+		Table:     strings.ToUpper(tablePieces[1]),
+		Records:   recordToBeWritten,
+		Schema:    schemaData,
+	})
+	offset, err := storage.AppendRecord(tablePieces[0], tablePieces[1], recordToBeWritten, schemaData, archiver)
+	if err != nil {
+		fmt.Printf("Error while writing tables: (%v)\n", err)
+		return errors.New(dbEngineError)
+	}
+	appendToCachedTable(tablePieces, recordToBeWritten, offset)
+	// the row is written, so a failure to update indexes does not fail the write,
+	// indexes are dropped from memory and rebuilt from table records when read next
+	if err := updateIndexes(tablePieces, rowID, recordToBeWritten, offset); err != nil {
+		fmt.Printf("Error while updating indexes, they are rebuilt when read next: (%v)\n", err)
 	}
+	return nil
+}
 
-	myMap := make(map[string]interface{})
-	var dataMap map[string]interface{}
-	if err := yaml.Unmarshal([]byte(newData), &dataMap); err != nil {
-		return "", errors.New("INVALID TABLE-DATA")
+func (db *DBEngine) writeTable() (string, error) {
+	if len(db.cmdArgs) != 1 {
+		return "", errors.New("INVALID TABLE-NAME, CANNOT WRITE TABLE")
 	}
-	myMap["row_id_"+common.GenerateRowID(len(newData))] = dataMap
 
-	recordToBeWritten, err := yaml.Marshal(myMap)
+	tablePieces, err := db.parseTableName()
 	if err != nil {
-		return "", errors.New("INVALID TABLE-DATA")
+		return "", err
+	}
+	lock := tableLockFor(tablePieces)
+	if err := lock.Lock(); err != nil {
+		return "", err
 	}
+	defer lock.Unlock()
 
-	f, err := os.OpenFile(tableFileName, os.O_APPEND|os.O_WRONLY, 0600)
-	defer f.Close()
+	if _, err = storage.StatTable(tablePieces[0], tablePieces[1]); os.IsNotExist(err) {
+		return "", errors.New("TABLE DOES NOT EXISTS")
+	}
+
+	schema, err := readTableSchema(tablePieces)
 	if err != nil {
-		fmt.Printf("Error while writing tables: (%v)\n", err)
-		return "", errors.New(dbEngineError)
+		return "", err
+	}
+	record, err := buildRecord(tablePieces, schema, db.payload)
+	if err != nil {
+		return "", err
+	}
+	rowID, err := generateRowID(tablePieces, schema)
+	if err != nil {
+		return "", err
+	}
+	if err := appendRecord(tablePieces, rowID, record, schema); err != nil {
+		return "", err
 	}
 
-	if _, err := f.WriteString(string(recordToBeWritten)); err != nil {
-		fmt.Printf("Error while writing tables: (%v)\n", err)
-		return "", errors.New(dbEngineError)
+	return fmt.Sprintf(`TABLE '%s:%s' WRITTEN. ROW-ID: %s`, tablePieces[0], tablePieces[1], rowID), nil
+}
+
+// rewriteTable replaces table records with records of tbl,
+// schema is replaced along with them when schema is not nil,
+// caller holds the lock of table
+func rewriteTable(tablePieces []string, tbl *models.DataTable, schema *models.TableSchema) error {
+	tableData, err := tbl.ToYaml()
+	if err != nil {
+		fmt.Printf("Error while rewriting table: (%v)\n", err)
+		return errors.New(dbEngineError)
+	}
+	var schemaData []byte
+	if schema != nil {
+		if schemaData, err = schema.ToYaml(); err != nil {
+			fmt.Printf("Error while rewriting table: (%v)\n", err)
+			return errors.New(dbEngineError)
+		}
 	}
 
-	return fmt.Sprintf(`TABLE '%s:%s' WRITTEN.`, tablePieces[0], tablePieces[1]), nil
+	tables.remove(tableKey(tablePieces))
+	archiver := newChangeArchiver(tablePieces[0], changeEntry{
+		Operation: changeRewrite,
+		Table:     strings.ToUpper(tablePieces[1]),
+		Records:   tableData,
+		Schema:    schemaData,
+	})
+	if err := storage.RewriteRecords(tablePieces[0], tablePieces[1], tableData, schemaData, archiver); err != nil {
+		fmt.Printf("Error while rewriting table: (%v)\n", err)
+		return errors.New(dbEngineError)
+	}
+	return nil
 }
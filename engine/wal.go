@@ -0,0 +1,417 @@
+package engine
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Every database keeps a write-ahead log of the changes made to its tables: appends,
+// table creation and deletion, rewrites of table records and schema writes.
+// A change is logged and flushed to disk before it is applied to table files,
+// once the files are flushed the change is committed, either by a commit entry
+// or by emptying the log when no other change is in flight.
+// A change made through a change archiver is archived to the change log of the database
+// once it is logged and before it is applied, a change failing to apply after it was
+// archived is archived as aborted before it is committed.
+// On server start changes which were logged but not committed are applied again,
+// for an append the table file is cut back to its size before the append, so a torn record
+// left behind by a crash is dropped before the record is appended again, other changes
+// carry the whole content of files they write. Index files are not logged, indexes
+// not matching table records are rebuilt. Changes applied again are archived unless they
+// were archived before the crash, changes archived as aborted are not applied again.
+//
+// Log entry format is "<body-length> <crc32-of-body>\n<json-body>",
+// an entry cut short or failing its checksum ends the log.
+
+// Operations of logged changes other than appends
+const (
+	walCreateTable = "create-table"
+	walDeleteTable = "delete-table"
+	walRewrite     = "rewrite"
+	walSchema      = "schema"
+)
+
+type walEntry struct {
+	Sequence uint64 `json:"sequence"`
+	Commit   bool   `json:"commit,omitempty"`
+	// Operation of change, empty for an append
+	Operation string `json:"operation,omitempty"`
+	// Table name of table file, relative to database directory
+	Table string `json:"table,omitempty"`
+	// Offset size of table file before the append
+	Offset int64 `json:"offset,omitempty"`
+	// Record yaml appended to table file, or every record of rewritten table
+	Record []byte `json:"record,omitempty"`
+	// Schema content of table schema after the change, if the change set it
+	Schema []byte `json:"schema,omitempty"`
+	// Change identifies the change in change log of database, if it is archived
+	Change string `json:"change,omitempty"`
+}
+
+type writeAheadLog struct {
+	mutex    sync.Mutex
+	path     string
+	file     *os.File
+	sequence uint64
+	inFlight int
+}
+
+var (
+	walsMutex sync.Mutex
+	wals      = make(map[string]*writeAheadLog)
+)
+
+// walFor returns write-ahead log of database stored at dbPath
+func walFor(dbPath string) *writeAheadLog {
+	path := filepath.Join(dbPath, walFileName)
+
+	walsMutex.Lock()
+	defer walsMutex.Unlock()
+
+	wal, ok := wals[path]
+	if !ok {
+		wal = &writeAheadLog{path: path}
+		wals[path] = wal
+	}
+	return wal
+}
+
+// closeWAL closes write-ahead log of database stored at dbPath, which is about to be deleted
+func closeWAL(dbPath string) {
+	path := filepath.Join(dbPath, walFileName)
+
+	walsMutex.Lock()
+	defer walsMutex.Unlock()
+
+	if wal, ok := wals[path]; ok {
+		wal.mutex.Lock()
+		if wal.file != nil {
+			wal.file.Close()
+		}
+		wal.mutex.Unlock()
+		delete(wals, path)
+	}
+}
+
+// frameLogEntry frames json body of a log entry as "<body-length> <crc32-of-body>\n<json-body>"
+func frameLogEntry(entry interface{}) ([]byte, error) {
+	body, err := json.Marshal(entry)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(fmt.Sprintf("%d %08x\n", len(body), crc32.ChecksumIEEE(body))), body...), nil
+}
+
+// splitLogEntries returns json bodies of framed log entries and length of data they take,
+// stopping at the first torn or corrupt entry
+func splitLogEntries(data []byte) ([][]byte, int) {
+	var bodies [][]byte
+	var length int
+	for len(data) > 0 {
+		newLine := bytes.IndexByte(data, '\n')
+		if newLine == -1 {
+			break
+		}
+		var bodyLength int
+		var checksum uint32
+		if _, err := fmt.Sscanf(string(data[:newLine]), "%d %08x", &bodyLength, &checksum); err != nil || bodyLength < 0 {
+			break
+		}
+		data = data[newLine+1:]
+		if len(data) < bodyLength || crc32.ChecksumIEEE(data[:bodyLength]) != checksum {
+			break
+		}
+		bodies = append(bodies, data[:bodyLength])
+		data = data[bodyLength:]
+		length += newLine + 1 + bodyLength
+	}
+	return bodies, length
+}
+
+// decodeWALEntries decodes log entries, stopping at the first torn or corrupt entry
+func decodeWALEntries(data []byte) []walEntry {
+	var entries []walEntry
+	bodies, _ := splitLogEntries(data)
+	for _, body := range bodies {
+		var entry walEntry
+		if err := json.Unmarshal(body, &entry); err != nil {
+			break
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+// write appends entry to log and flushes it to disk, caller holds the log mutex
+func (wal *writeAheadLog) write(entry walEntry) error {
+	if wal.file == nil {
+		file, err := os.OpenFile(wal.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+		if err != nil {
+			return err
+		}
+		wal.file = file
+	}
+
+	encodedEntry, err := frameLogEntry(entry)
+	if err != nil {
+		return err
+	}
+	if _, err := wal.file.Write(encodedEntry); err != nil {
+		return err
+	}
+	return wal.file.Sync()
+}
+
+// log records a change before it is applied and returns its sequence
+func (wal *writeAheadLog) log(entry walEntry) (uint64, error) {
+	wal.mutex.Lock()
+	defer wal.mutex.Unlock()
+
+	wal.sequence++
+	entry.Sequence = wal.sequence
+	if err := wal.write(entry); err != nil {
+		return 0, err
+	}
+	wal.inFlight++
+	return entry.Sequence, nil
+}
+
+// commit records that a change has been applied and flushed to disk
+func (wal *writeAheadLog) commit(sequence uint64) error {
+	wal.mutex.Lock()
+	defer wal.mutex.Unlock()
+
+	wal.inFlight--
+	if wal.inFlight == 0 {
+		// nothing else is in flight, every logged change is on disk
+		if err := wal.file.Truncate(0); err != nil {
+			return err
+		}
+		return wal.file.Sync()
+	}
+	return wal.write(walEntry{Sequence: sequence, Commit: true})
+}
+
+// logAndAppend appends record to table file through write-ahead log of database
+// stored at dbPath and returns the offset record was appended at, schema is the new
+// content of schema file, nil when unchanged, caller holds the lock of table
+func logAndAppend(dbPath string, tableFileName string, schemaFileName string, record []byte, schema []byte, archiver ChangeArchiver) (int64, error) {
+	tableFileInfo, err := os.Stat(tableFileName)
+	if err != nil {
+		return 0, err
+	}
+	offset := tableFileInfo.Size()
+
+	wal := walFor(dbPath)
+	sequence, err := wal.log(walEntry{
+		Table:  filepath.Base(tableFileName),
+		Offset: offset,
+		Record: record,
+		Schema: schema,
+		Change: changeID(archiver),
+	})
+	if err != nil {
+		return 0, err
+	}
+	if err := archiveChange(archiver); err != nil {
+		wal.commit(sequence)
+		return 0, err
+	}
+
+	if err := applyWALAppend(tableFileName, schemaFileName, offset, record, schema); err != nil {
+		// undo what was applied, so that the failed append is not applied again on recovery
+		os.Truncate(tableFileName, offset)
+		abortChange(archiver)
+		wal.commit(sequence)
+		return 0, err
+	}
+	return offset, wal.commit(sequence)
+}
+
+// applyWALAppend writes schema, cuts table file back to offset,
+// appends record to it and flushes both to disk
+func applyWALAppend(tableFileName string, schemaFileName string, offset int64, record []byte, schema []byte) error {
+	if schema != nil {
+		if err := rewriteFile(schemaFileName, schema); err != nil {
+			return err
+		}
+	}
+
+	f, err := os.OpenFile(tableFileName, os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if err := f.Truncate(offset); err != nil {
+		return err
+	}
+	if _, err := f.WriteAt(record, offset); err != nil {
+		return err
+	}
+	return f.Sync()
+}
+
+// logAndApply applies a change other than an append to table files through write-ahead log
+// of database stored at dbPath, caller holds the lock of table
+func logAndApply(dbPath string, entry walEntry, archiver ChangeArchiver) error {
+	wal := walFor(dbPath)
+	entry.Change = changeID(archiver)
+	sequence, err := wal.log(entry)
+	if err != nil {
+		return err
+	}
+	if err := archiveChange(archiver); err != nil {
+		wal.commit(sequence)
+		return err
+	}
+	if err := applyWALChange(dbPath, entry); err != nil {
+		// a failed change is not applied again on recovery
+		abortChange(archiver)
+		wal.commit(sequence)
+		return err
+	}
+	return wal.commit(sequence)
+}
+
+// applyWALChange applies a change other than an append to table files and flushes them
+// to disk, applying a change again leaves table files as applying it once does
+func applyWALChange(dbPath string, entry walEntry) error {
+	tableFileName := filepath.Join(dbPath, entry.Table)
+	tableBaseName := strings.TrimSuffix(tableFileName, tableFileSuffix)
+	schemaFileName := tableBaseName + schemaFileSuffix
+
+	switch entry.Operation {
+	case walCreateTable:
+		return rewriteFiles([]string{schemaFileName, tableFileName}, [][]byte{entry.Schema, {}})
+	case walDeleteTable:
+		// table file goes first, a table is gone once its table file is
+		for _, suffix := range []string{tableFileSuffix, schemaFileSuffix, indexFileSuffix, lockFileSuffix} {
+			if err := os.Remove(tableBaseName + suffix); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+		return syncDir(dbPath)
+	case walRewrite:
+		fileNames := []string{tableFileName}
+		contents := [][]byte{entry.Record}
+		if entry.Schema != nil {
+			fileNames = append(fileNames, schemaFileName)
+			contents = append(contents, entry.Schema)
+		}
+		return rewriteFiles(fileNames, contents)
+	case walSchema:
+		return rewriteFile(schemaFileName, entry.Schema)
+	}
+	return fmt.Errorf("unknown operation '%s' in write-ahead log", entry.Operation)
+}
+
+func writeFileSynced(fileName string, data []byte) error {
+	f, err := os.OpenFile(fileName, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if _, err := f.Write(data); err != nil {
+		return err
+	}
+	return f.Sync()
+}
+
+// recoverDatabase applies changes logged but not committed in write-ahead log
+// of database stored at dbPath and empties the log
+func recoverDatabase(dbPath string) error {
+	if err := removeTempFiles(dbPath); err != nil {
+		return err
+	}
+
+	walPath := filepath.Join(dbPath, walFileName)
+	walData, err := ioutil.ReadFile(walPath)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	entries := decodeWALEntries(walData)
+	committed := make(map[uint64]bool)
+	for _, entry := range entries {
+		if entry.Commit {
+			committed[entry.Sequence] = true
+		}
+	}
+
+	dbName := strings.TrimSuffix(filepath.Base(dbPath), dbFileSuffix)
+	var replayed int
+	for _, entry := range entries {
+		if entry.Commit || committed[entry.Sequence] {
+			continue
+		}
+		tableFileName := filepath.Join(dbPath, entry.Table)
+		tableFileInfo, err := os.Stat(tableFileName)
+		if entry.Operation == walCreateTable || entry.Operation == walDeleteTable {
+			apply, err := reconcileChange(dbName, entry)
+			if err != nil {
+				return err
+			}
+			if !apply {
+				continue
+			}
+			if err := applyWALChange(dbPath, entry); err != nil {
+				return err
+			}
+			replayed++
+			continue
+		}
+		if os.IsNotExist(err) {
+			// table was deleted after the change
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if entry.Operation == "" && tableFileInfo.Size() < entry.Offset {
+			fmt.Printf("Skipping logged append to %s, table is shorter than logged offset\n", tableFileName)
+			continue
+		}
+		apply, err := reconcileChange(dbName, entry)
+		if err != nil {
+			return err
+		}
+		if !apply {
+			if entry.Operation == "" {
+				// an aborted append is cut back, as it is when it fails
+				if err := os.Truncate(tableFileName, entry.Offset); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		if entry.Operation != "" {
+			if err := applyWALChange(dbPath, entry); err != nil {
+				return err
+			}
+			replayed++
+			continue
+		}
+		schemaFileName := strings.TrimSuffix(tableFileName, tableFileSuffix) + schemaFileSuffix
+		if err := applyWALAppend(tableFileName, schemaFileName, entry.Offset, entry.Record, entry.Schema); err != nil {
+			return err
+		}
+		replayed++
+	}
+	if replayed > 0 || len(walData) > 0 {
+		fmt.Printf("Recovered %s: %d changes applied from write-ahead log\n", dbPath, replayed)
+	}
+
+	return writeFileSynced(walPath, []byte{})
+}
@@ -0,0 +1,232 @@
+package engine
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// frameWALEntries frames entries as they are written to write-ahead log
+func frameWALEntries(t *testing.T, entries ...walEntry) []byte {
+	t.Helper()
+	var data []byte
+	for _, entry := range entries {
+		framedEntry, err := frameLogEntry(entry)
+		if err != nil {
+			t.Fatalf("frameLogEntry failed: %v", err)
+		}
+		data = append(data, framedEntry...)
+	}
+	return data
+}
+
+func TestRecoverDatabase(t *testing.T) {
+	const (
+		ann    = "row_id_1:\n  name: ann\n"
+		bob    = "row_id_2:\n  name: bob\n"
+		cid    = "row_id_3:\n  name: cid\n"
+		schema = "columns:\n- name\n"
+	)
+	appendBob := walEntry{Sequence: 1, Table: "T.tbl", Offset: int64(len(ann)), Record: []byte(bob)}
+	appendCid := walEntry{Sequence: 2, Table: "T.tbl", Offset: int64(len(ann + bob)), Record: []byte(cid)}
+
+	tests := []struct {
+		name string
+		// files of database before recovery, by file name
+		files map[string]string
+		wal   []byte
+		// changes archived as aborted before the crash
+		aborted []string
+		// files of database after recovery, and files missing after it
+		expected map[string]string
+		missing  []string
+	}{
+		{
+			name:     "uncommitted append",
+			files:    map[string]string{"T.tbl": ann},
+			wal:      frameWALEntries(t, appendBob),
+			expected: map[string]string{"T.tbl": ann + bob},
+		},
+		{
+			name:     "uncommitted append cut short in table file",
+			files:    map[string]string{"T.tbl": ann + bob[:5]},
+			wal:      frameWALEntries(t, appendBob),
+			expected: map[string]string{"T.tbl": ann + bob},
+		},
+		{
+			name:     "committed append",
+			files:    map[string]string{"T.tbl": ann + bob},
+			wal:      frameWALEntries(t, appendBob, walEntry{Sequence: 1, Commit: true}),
+			expected: map[string]string{"T.tbl": ann + bob},
+		},
+		{
+			name:     "append to table shorter than offset",
+			files:    map[string]string{"T.tbl": ""},
+			wal:      frameWALEntries(t, appendBob),
+			expected: map[string]string{"T.tbl": ""},
+		},
+		{
+			name:     "torn tail",
+			files:    map[string]string{"T.tbl": ann},
+			wal:      bytes.TrimSuffix(frameWALEntries(t, appendBob, appendCid), []byte("}")),
+			expected: map[string]string{"T.tbl": ann + bob},
+		},
+		{
+			name:     "torn header",
+			files:    map[string]string{"T.tbl": ann},
+			wal:      append(frameWALEntries(t, appendBob), []byte("27")...),
+			expected: map[string]string{"T.tbl": ann + bob},
+		},
+		{
+			// an entry failing its checksum ends the log, entries after it are not applied
+			name:     "bad checksum",
+			files:    map[string]string{"T.tbl": ann},
+			wal:      bytes.Replace(frameWALEntries(t, appendBob, appendCid), []byte(`"sequence":1`), []byte(`"sequence":7`), 1),
+			expected: map[string]string{"T.tbl": ann},
+		},
+		{
+			name:  "uncommitted rewrite",
+			files: map[string]string{"T.tbl": ann + bob, "T.schema": schema},
+			wal: frameWALEntries(t, walEntry{Sequence: 1, Operation: walRewrite, Table: "T.tbl",
+				Record: []byte(cid), Schema: []byte("columns:\n- name\n- age\n")}),
+			expected: map[string]string{"T.tbl": cid, "T.schema": "columns:\n- name\n- age\n"},
+		},
+		{
+			name:    "uncommitted rewrite of deleted table",
+			files:   map[string]string{},
+			wal:     frameWALEntries(t, walEntry{Sequence: 1, Operation: walRewrite, Table: "T.tbl", Record: []byte(cid)}),
+			missing: []string{"T.tbl"},
+		},
+		{
+			name:     "uncommitted create-table",
+			files:    map[string]string{},
+			wal:      frameWALEntries(t, walEntry{Sequence: 1, Operation: walCreateTable, Table: "T.tbl", Schema: []byte(schema)}),
+			expected: map[string]string{"T.tbl": "", "T.schema": schema},
+		},
+		{
+			name:     "uncommitted delete-table",
+			files:    map[string]string{"T.tbl": ann, "T.schema": schema, "T.idx": "indexes", "U.tbl": bob},
+			wal:      frameWALEntries(t, walEntry{Sequence: 1, Operation: walDeleteTable, Table: "T.tbl"}),
+			expected: map[string]string{"U.tbl": bob},
+			missing:  []string{"T.tbl", "T.schema", "T.idx"},
+		},
+		{
+			name:  "create-table then appends",
+			files: map[string]string{},
+			wal: frameWALEntries(t,
+				walEntry{Sequence: 1, Operation: walCreateTable, Table: "T.tbl", Schema: []byte(schema)},
+				walEntry{Sequence: 2, Table: "T.tbl", Offset: 0, Record: []byte(ann)},
+				walEntry{Sequence: 3, Table: "T.tbl", Offset: int64(len(ann)), Record: []byte(bob)}),
+			expected: map[string]string{"T.tbl": ann + bob, "T.schema": schema},
+		},
+		{
+			// an aborted append is cut back, as it is when it fails
+			name:     "aborted append",
+			files:    map[string]string{"T.tbl": ann + bob[:5]},
+			wal:      frameWALEntries(t, walEntry{Sequence: 1, Table: "T.tbl", Offset: int64(len(ann)), Record: []byte(bob), Change: "c-1"}),
+			aborted:  []string{"c-1"},
+			expected: map[string]string{"T.tbl": ann},
+		},
+		{
+			name:  "aborted rewrite",
+			files: map[string]string{"T.tbl": ann},
+			wal: frameWALEntries(t,
+				walEntry{Sequence: 1, Operation: walRewrite, Table: "T.tbl", Record: []byte(cid), Change: "c-1"}),
+			aborted:  []string{"c-1"},
+			expected: map[string]string{"T.tbl": ann},
+		},
+		{
+			name:     "aborted delete-table",
+			files:    map[string]string{"T.tbl": ann},
+			wal:      frameWALEntries(t, walEntry{Sequence: 1, Operation: walDeleteTable, Table: "T.tbl", Change: "c-1"}),
+			aborted:  []string{"c-1"},
+			expected: map[string]string{"T.tbl": ann},
+		},
+	}
+	for _, test := range tests {
+		useMemoryStorage(t)
+		SetChangeLog(t.TempDir(), 0, 0)
+		dbPath := filepath.Join(t.TempDir(), "D"+dbFileSuffix)
+		if err := os.Mkdir(dbPath, 0755); err != nil {
+			t.Fatal(err)
+		}
+		for fileName, content := range test.files {
+			if err := ioutil.WriteFile(filepath.Join(dbPath, fileName), []byte(content), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := ioutil.WriteFile(filepath.Join(dbPath, walFileName), test.wal, 0644); err != nil {
+			t.Fatal(err)
+		}
+		for _, id := range test.aborted {
+			for _, operation := range []string{changeAppend, changeAbort} {
+				if err := changeLogFor("D").append(changeEntry{Operation: operation, ID: id, Table: "T"}); err != nil {
+					t.Fatal(err)
+				}
+			}
+		}
+
+		if err := recoverDatabase(dbPath); err != nil {
+			t.Errorf("%s: recoverDatabase failed: %v", test.name, err)
+			continue
+		}
+		for fileName, expected := range test.expected {
+			content, err := ioutil.ReadFile(filepath.Join(dbPath, fileName))
+			if err != nil {
+				t.Errorf("%s: reading %s failed: %v", test.name, fileName, err)
+				continue
+			}
+			if string(content) != expected {
+				t.Errorf("%s: %s = %q, expected %q", test.name, fileName, content, expected)
+			}
+		}
+		for _, fileName := range test.missing {
+			if _, err := os.Stat(filepath.Join(dbPath, fileName)); !os.IsNotExist(err) {
+				t.Errorf("%s: %s exists after recovery, expected it missing (%v)", test.name, fileName, err)
+			}
+		}
+		if walData, err := ioutil.ReadFile(filepath.Join(dbPath, walFileName)); err != nil || len(walData) != 0 {
+			t.Errorf("%s: write-ahead log holds %q after recovery, expected it empty (%v)", test.name, walData, err)
+		}
+	}
+}
+
+func TestRecoverDatabaseArchivesChanges(t *testing.T) {
+	useMemoryStorage(t)
+	SetChangeLog(t.TempDir(), 0, 0)
+	dbPath := filepath.Join(t.TempDir(), "D"+dbFileSuffix)
+	if err := os.Mkdir(dbPath, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dbPath, "T.tbl"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	// c-1 was archived before the crash, c-2 was not
+	log := changeLogFor("D")
+	if err := log.append(changeEntry{Operation: changeAppend, ID: "c-1", Table: "T"}); err != nil {
+		t.Fatal(err)
+	}
+	wal := frameWALEntries(t,
+		walEntry{Sequence: 1, Table: "T.tbl", Offset: 0, Record: []byte("row_id_1:\n  name: ann\n"), Change: "c-1"},
+		walEntry{Sequence: 2, Table: "T.tbl", Offset: 22, Record: []byte("row_id_2:\n  name: bob\n"), Change: "c-2"})
+	if err := ioutil.WriteFile(filepath.Join(dbPath, walFileName), wal, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := recoverDatabase(dbPath); err != nil {
+		t.Fatalf("recoverDatabase failed: %v", err)
+	}
+	changes, err := log.changesAfter(0)
+	if err != nil {
+		t.Fatalf("changesAfter failed: %v", err)
+	}
+	var ids []string
+	for _, change := range changes {
+		ids = append(ids, change.ID)
+	}
+	if len(ids) != 2 || ids[0] != "c-1" || ids[1] != "c-2" {
+		t.Errorf("change log holds changes %v after recovery, expected [c-1 c-2]", ids)
+	}
+}
@@ -0,0 +1,135 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Aggregate functions
+const (
+	AggregateCount = "count"
+	AggregateSum   = "sum"
+	AggregateAvg   = "avg"
+	AggregateMin   = "min"
+	AggregateMax   = "max"
+)
+
+// ErrNotNumeric returned when sum or avg is given a value which is not a number
+var ErrNotNumeric = errors.New("VALUE IS NOT NUMERIC")
+
+// Aggregator accumulates column values of rows for an aggregate function,
+// null values are left out. Sum of int values is an int, sum turns into a float64
+// once a float64 value is added or the sum overflows an int
+type Aggregator struct {
+	function string
+	count    int64
+	intSum   int64
+	floatSum float64
+	isFloat  bool
+	extreme  interface{}
+}
+
+// NewAggregator returns aggregator of aggregate function
+func NewAggregator(function string) (*Aggregator, error) {
+	switch strings.ToLower(function) {
+	case AggregateCount, AggregateSum, AggregateAvg, AggregateMin, AggregateMax:
+		return &Aggregator{function: strings.ToLower(function)}, nil
+	}
+	return nil, fmt.Errorf("UNKNOWN AGGREGATE FUNCTION '%s'", function)
+}
+
+// toInt returns integer value of data, when data is an integer fitting an int64
+func toInt(data interface{}) (int64, bool) {
+	switch value := data.(type) {
+	case int:
+		return int64(value), true
+	case int64:
+		return value, true
+	case uint64:
+		if value <= math.MaxInt64 {
+			return int64(value), true
+		}
+	}
+	return 0, false
+}
+
+func (agg *Aggregator) addNumber(value interface{}) error {
+	if !agg.isFloat {
+		if number, ok := toInt(value); ok {
+			sum := agg.intSum + number
+			// overflow when both have the same sign and the sum does not
+			if (number > 0 && sum < agg.intSum) || (number < 0 && sum > agg.intSum) {
+				agg.isFloat = true
+				agg.floatSum = float64(agg.intSum) + float64(number)
+				return nil
+			}
+			agg.intSum = sum
+			return nil
+		}
+	}
+	number, ok := toFloat(value)
+	if !ok {
+		return ErrNotNumeric
+	}
+	if !agg.isFloat {
+		agg.isFloat = true
+		agg.floatSum = float64(agg.intSum)
+	}
+	agg.floatSum += number
+	return nil
+}
+
+// Add accumulates value of a row
+func (agg *Aggregator) Add(value interface{}) error {
+	if value == nil {
+		return nil
+	}
+	switch agg.function {
+	case AggregateSum, AggregateAvg:
+		if err := agg.addNumber(value); err != nil {
+			return err
+		}
+	case AggregateMin, AggregateMax:
+		if agg.extreme == nil {
+			agg.extreme = value
+			break
+		}
+		result, err := CompareData(value, agg.extreme)
+		if err != nil {
+			return err
+		}
+		if (agg.function == AggregateMin && result < 0) || (agg.function == AggregateMax && result > 0) {
+			agg.extreme = value
+		}
+	}
+	agg.count++
+	return nil
+}
+
+// Result returns aggregated value, nil when no values were added to
+// an aggregate other than count
+func (agg *Aggregator) Result() interface{} {
+	switch agg.function {
+	case AggregateCount:
+		return agg.count
+	case AggregateSum:
+		if agg.count == 0 {
+			return nil
+		}
+		if agg.isFloat {
+			return agg.floatSum
+		}
+		return agg.intSum
+	case AggregateAvg:
+		if agg.count == 0 {
+			return nil
+		}
+		if agg.isFloat {
+			return agg.floatSum / float64(agg.count)
+		}
+		return float64(agg.intSum) / float64(agg.count)
+	}
+	return agg.extreme
+}
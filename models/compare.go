@@ -0,0 +1,142 @@
+package models
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// ErrIncomparable returned when two column values cannot be compared
+var ErrIncomparable = errors.New("VALUES ARE NOT COMPARABLE")
+
+func toFloat(data interface{}) (float64, bool) {
+	switch value := data.(type) {
+	case int:
+		return float64(value), true
+	case int64:
+		return float64(value), true
+	case uint64:
+		return float64(value), true
+	case float64:
+		return value, true
+	default:
+		return 0, false
+	}
+}
+
+// toInteger returns integer column value as int64, or as uint64 when it is beyond int64
+func toInteger(data interface{}) (int64, uint64, bool) {
+	switch value := data.(type) {
+	case int:
+		return int64(value), 0, true
+	case int64:
+		return value, 0, true
+	case uint64:
+		if value > math.MaxInt64 {
+			return 0, value, true
+		}
+		return int64(value), 0, true
+	default:
+		return 0, 0, false
+	}
+}
+
+// compareIntegers compares integer column values exactly, ok is false unless both are integers
+func compareIntegers(first, second interface{}) (int, bool) {
+	firstValue, firstBeyond, ok := toInteger(first)
+	if !ok {
+		return 0, false
+	}
+	secondValue, secondBeyond, ok := toInteger(second)
+	if !ok {
+		return 0, false
+	}
+	switch {
+	case firstBeyond != secondBeyond:
+		// a value beyond int64 is greater than any other value
+		if firstBeyond > secondBeyond {
+			return 1, true
+		}
+		return -1, true
+	case firstValue < secondValue:
+		return -1, true
+	case firstValue > secondValue:
+		return 1, true
+	}
+	return 0, true
+}
+
+// CompareData compares two column values of the same kind,
+// returns -1, 0 or 1 as first value is less, equal or greater than the second.
+// int and float64 values are compared numerically with each other, integers exactly
+func CompareData(first, second interface{}) (int, error) {
+	if first == nil || second == nil {
+		switch {
+		case first == nil && second == nil:
+			return 0, nil
+		case first == nil:
+			return -1, nil
+		default:
+			return 1, nil
+		}
+	}
+
+	if result, ok := compareIntegers(first, second); ok {
+		return result, nil
+	}
+	if firstNumber, ok := toFloat(first); ok {
+		secondNumber, ok := toFloat(second)
+		if !ok {
+			return 0, ErrIncomparable
+		}
+		switch {
+		case firstNumber < secondNumber:
+			return -1, nil
+		case firstNumber > secondNumber:
+			return 1, nil
+		}
+		return 0, nil
+	}
+
+	switch firstValue := first.(type) {
+	case string:
+		secondValue, ok := second.(string)
+		if !ok {
+			return 0, ErrIncomparable
+		}
+		return strings.Compare(firstValue, secondValue), nil
+	case bool:
+		secondValue, ok := second.(bool)
+		if !ok {
+			return 0, ErrIncomparable
+		}
+		switch {
+		case firstValue == secondValue:
+			return 0, nil
+		case secondValue:
+			return -1, nil
+		}
+		return 1, nil
+	}
+	return 0, ErrIncomparable
+}
+
+// ParseData converts text into a value of the same kind as sample,
+// so that it can be compared with sample using CompareData
+func ParseData(sample interface{}, text string) (interface{}, error) {
+	switch sample.(type) {
+	case int, int64, uint64:
+		if value, err := strconv.Atoi(text); err == nil {
+			return value, nil
+		}
+		return strconv.ParseFloat(text, 64)
+	case float64:
+		return strconv.ParseFloat(text, 64)
+	case bool:
+		return strconv.ParseBool(text)
+	case string:
+		return text, nil
+	}
+	return nil, ErrIncomparable
+}
@@ -1,8 +1,9 @@
 package models
 
 import (
+	"encoding/json"
 	"fmt"
-	"reflect"
+	"sort"
 	"strconv"
 	"strings"
 
@@ -14,6 +15,34 @@ type DataColumn struct {
 	ColumnData interface{}
 }
 
+// String returns string representation of column data,
+// lists and maps are represented as inline json
+func (col DataColumn) String() string {
+	if col.ColumnData == nil {
+		return ""
+	}
+	var columnString string
+	switch value := col.ColumnData.(type) {
+	case string:
+		columnString = value
+	case int:
+		columnString = strconv.Itoa(value)
+	case int64:
+		columnString = strconv.FormatInt(value, 10)
+	case uint64:
+		columnString = strconv.FormatUint(value, 10)
+	case float64:
+		columnString = strconv.FormatFloat(value, 'f', -1, 64)
+	case bool:
+		columnString = strconv.FormatBool(value)
+	case []interface{}, map[string]interface{}:
+		columnString = readNestedData(value)
+	default:
+		columnString = fmt.Sprint(value)
+	}
+	return columnString
+}
+
 // DataRecord database record
 type DataRecord struct {
 	Columns map[string]DataColumn
@@ -22,69 +51,170 @@ type DataRecord struct {
 // DataTable database table
 type DataTable struct {
 	Records map[string]DataRecord
+	// RowIDs lists row-ids in the order records are to be listed,
+	// when empty records are listed in row-id order
+	RowIDs []string
+	// Columns lists column-names in the order columns are to be listed,
+	// when empty columns are listed in column-name order
+	Columns []string
 }
 
-// ToString returns string representation of table
-func (tbl *DataTable) ToString() string {
-	var tableData [][]string
-	var numberOfColumns int
+// OrderedRowIDs returns row-ids in the order records are to be listed
+func (tbl *DataTable) OrderedRowIDs() []string {
+	if len(tbl.RowIDs) > 0 {
+		return tbl.RowIDs
+	}
+	rowIDs := make([]string, 0, len(tbl.Records))
+	for rowID := range tbl.Records {
+		rowIDs = append(rowIDs, rowID)
+	}
+	sort.Strings(rowIDs)
+	return rowIDs
+}
 
-	//Find number of columns
-	for _, value := range tbl.Records {
-		numberOfColumns = len(value.Columns)
-		break
+// OrderedColumns returns column-names in the order columns are to be listed,
+// columns found in records but missing from Columns are listed last, in column-name order
+func (tbl *DataTable) OrderedColumns() []string {
+	tableColumns := append([]string(nil), tbl.Columns...)
+	knownColumns := make(map[string]bool)
+	for _, columnName := range tableColumns {
+		knownColumns[columnName] = true
 	}
 
-	//Populate column-names list
-	tableColumns := make([]string, numberOfColumns)
-	for _, value := range tbl.Records {
-		var i int
-		for key := range value.Columns {
-			tableColumns[i] = key
-			i++
+	var extraColumns []string
+	for _, record := range tbl.Records {
+		for columnName := range record.Columns {
+			if !knownColumns[columnName] {
+				knownColumns[columnName] = true
+				extraColumns = append(extraColumns, columnName)
+			}
 		}
-		break
 	}
+	sort.Strings(extraColumns)
+	return append(tableColumns, extraColumns...)
+}
+
+// ApplySchema lists table columns in the order declared by schema
+func (tbl *DataTable) ApplySchema(schema *TableSchema) {
+	if len(schema.Columns) == 0 {
+		return
+	}
+	tbl.Columns = schema.ColumnNames()
+}
+
+// ToString returns string representation of table
+func (tbl *DataTable) ToString() string {
+	tableColumns := tbl.OrderedColumns()
+	var returnTableString = strings.Join(tableColumns, "|")
 
 	//Read column data as per column-name sequence
-	for _, tableRecord := range tbl.Records {
-		tmpRecord := make([]string, numberOfColumns)
-		for key, value := range tableRecord.Columns {
-			columnIndex := findColumnIndex(tableColumns, key)
-			if columnIndex == -1 {
-				return ""
+	for _, rowID := range tbl.OrderedRowIDs() {
+		tableRecord := tbl.Records[rowID]
+		tmpRecord := make([]string, len(tableColumns))
+		for columnIndex, columnName := range tableColumns {
+			if value, ok := tableRecord.Columns[columnName]; ok {
+				tmpRecord[columnIndex] = value.String()
 			}
+		}
+		returnTableString += "\n" + strings.Join(tmpRecord, "|")
+	}
+	return returnTableString
+}
 
-			switch reflect.TypeOf(value.ColumnData).String() {
-			case "string":
-				tmpRecord[columnIndex] = value.ColumnData.(string)
-			case "int":
-				tmpRecord[columnIndex] = strconv.Itoa(value.ColumnData.(int))
-			case "float64":
-				tmpRecord[columnIndex] = strconv.FormatFloat(value.ColumnData.(float64), 'f', -1, 64)
-			case "bool":
-				tmpRecord[columnIndex] = strconv.FormatBool(value.ColumnData.(bool))
-			default:
-				tmpRecord[columnIndex] = reflect.TypeOf(value.ColumnData).String()
-			}
-			if strings.Contains(reflect.TypeOf(value.ColumnData).String(), "interface") {
-				tmpRecord[columnIndex] = readInterfaceArray(value.ColumnData)
+// ToYaml returns yaml representation of table, which ParseYaml reads back,
+// rows and their columns are written in the order they are to be listed
+func (tbl *DataTable) ToYaml() ([]byte, error) {
+	tableColumns := tbl.OrderedColumns()
+	tableMap := make(yaml.MapSlice, 0, len(tbl.Records))
+	for _, rowID := range tbl.OrderedRowIDs() {
+		recordMap := yaml.MapSlice{}
+		for _, columnName := range tableColumns {
+			if value, ok := tbl.Records[rowID].Columns[columnName]; ok {
+				recordMap = append(recordMap, yaml.MapItem{Key: columnName, Value: value.ColumnData})
 			}
 		}
-		tableData = append(tableData, tmpRecord)
+		tableMap = append(tableMap, yaml.MapItem{Key: rowID, Value: recordMap})
 	}
+	if len(tableMap) == 0 {
+		return []byte{}, nil
+	}
+	return yaml.Marshal(tableMap)
+}
 
-	var returnTableString = strings.Join(tableColumns, "|")
+// DeleteRecord removes record of row-id from table
+func (tbl *DataTable) DeleteRecord(rowID string) {
+	if _, ok := tbl.Records[rowID]; !ok {
+		return
+	}
+	delete(tbl.Records, rowID)
+	for i, id := range tbl.RowIDs {
+		if id == rowID {
+			tbl.RowIDs = append(tbl.RowIDs[:i], tbl.RowIDs[i+1:]...)
+			break
+		}
+	}
+}
 
-	for _, value := range tableData {
-		returnTableString += "\n" + strings.Join(value, "|")
+// SetRecord sets record of row-id the way a record appended to table document is read,
+// a new row is listed last and columns not known to table are listed after known ones
+func (tbl *DataTable) SetRecord(rowID string, record DataRecord, columnNames []string) {
+	if _, ok := tbl.Records[rowID]; !ok {
+		tbl.RowIDs = append(tbl.RowIDs, rowID)
+	}
+	tbl.Records[rowID] = record
+
+	knownColumns := make(map[string]bool, len(tbl.Columns))
+	for _, columnName := range tbl.Columns {
+		knownColumns[columnName] = true
+	}
+	for _, columnName := range columnNames {
+		if !knownColumns[columnName] {
+			knownColumns[columnName] = true
+			tbl.Columns = append(tbl.Columns, columnName)
+		}
+	}
+}
+
+// Copy returns a copy of table which can be reordered without changing table,
+// records are shared with table
+func (tbl *DataTable) Copy() *DataTable {
+	records := make(map[string]DataRecord, len(tbl.Records))
+	for rowID, record := range tbl.Records {
+		records[rowID] = record
+	}
+	return &DataTable{
+		Records: records,
+		RowIDs:  append([]string(nil), tbl.RowIDs...),
+		Columns: append([]string(nil), tbl.Columns...),
+	}
+}
+
+// Project returns table holding only data addressed by column paths, listed in their order,
+// every path becomes a column named after it
+func (tbl *DataTable) Project(paths []string) *DataTable {
+	records := make(map[string]DataRecord, len(tbl.Records))
+	for rowID, record := range tbl.Records {
+		projected := DataRecord{Columns: make(map[string]DataColumn, len(paths))}
+		for _, path := range paths {
+			if value, ok := record.Lookup(path); ok {
+				projected.Columns[path] = value
+			}
+		}
+		records[rowID] = projected
+	}
+	return &DataTable{
+		Records: records,
+		RowIDs:  append([]string(nil), tbl.RowIDs...),
+		Columns: append([]string(nil), paths...),
 	}
-	return returnTableString
 }
 
-// ParseYaml parses yaml document content
+// ParseYaml parses yaml document content,
+// rows and columns are listed in the order they are first found in the document.
+// A row-id repeated later in the document replaces the earlier record,
+// a row-id with null value deletes the earlier record
 func ParseYaml(content []byte) (*DataTable, bool) {
-	var verificationMap map[string]interface{}
+	var verificationMap yaml.MapSlice
 	if err := yaml.Unmarshal(content, &verificationMap); err != nil {
 		fmt.Printf("1.1 >> Error while parsing data: (%v)\n", err)
 		return nil, false
@@ -92,10 +222,17 @@ func ParseYaml(content []byte) (*DataTable, bool) {
 
 	var table DataTable
 	table.Records = make(map[string]DataRecord)
-	for key, value := range verificationMap {
+	knownColumns := make(map[string]bool)
+	for _, item := range verificationMap {
+		key := fmt.Sprint(item.Key)
+		if item.Value == nil {
+			// a row-id with no columns marks a deleted row
+			table.DeleteRecord(key)
+			continue
+		}
 		var tmpRecord DataRecord
 		var yamlRecord map[string]interface{}
-		dataValue, err := yaml.Marshal(value)
+		dataValue, err := yaml.Marshal(item.Value)
 		if err != nil {
 			fmt.Printf("2.1 >> Error while byting data: (%v)\n", err)
 			return nil, false
@@ -107,23 +244,65 @@ func ParseYaml(content []byte) (*DataTable, bool) {
 
 		tmpRecord.Columns = make(map[string]DataColumn)
 		for columnName, columnValue := range yamlRecord {
-			tmpRecord.Columns[columnName] = DataColumn{ColumnData: columnValue}
+			tmpRecord.Columns[columnName] = DataColumn{ColumnData: NormalizeData(columnValue)}
+		}
+		if columns, ok := item.Value.(yaml.MapSlice); ok {
+			for _, column := range columns {
+				columnName := fmt.Sprint(column.Key)
+				if !knownColumns[columnName] {
+					knownColumns[columnName] = true
+					table.Columns = append(table.Columns, columnName)
+				}
+			}
+		}
+
+		if _, ok := table.Records[key]; !ok {
+			table.RowIDs = append(table.RowIDs, key)
 		}
 		table.Records[key] = tmpRecord
 	}
 	return &table, true
 }
 
-func findColumnIndex(columns []string, column string) int {
-	for columnIndex, columnName := range columns {
-		if columnName == column {
-			return columnIndex
-		}
+func readNestedData(data interface{}) string {
+	nestedData, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Sprint(data)
 	}
-	return -1
+	return string(nestedData)
 }
-func readInterfaceArray(data interface{}) string {
-	return "arrays-not-supported-currently"
+
+// NormalizeData converts nested yaml maps to maps keyed by strings,
+// so that column data is made of string, int, float64, bool,
+// []interface{} and map[string]interface{} values
+func NormalizeData(data interface{}) interface{} {
+	switch value := data.(type) {
+	case map[interface{}]interface{}:
+		normalizedMap := make(map[string]interface{}, len(value))
+		for key, item := range value {
+			normalizedMap[fmt.Sprint(key)] = NormalizeData(item)
+		}
+		return normalizedMap
+	case yaml.MapSlice:
+		normalizedMap := make(map[string]interface{}, len(value))
+		for _, item := range value {
+			normalizedMap[fmt.Sprint(item.Key)] = NormalizeData(item.Value)
+		}
+		return normalizedMap
+	case map[string]interface{}:
+		normalizedMap := make(map[string]interface{}, len(value))
+		for key, item := range value {
+			normalizedMap[key] = NormalizeData(item)
+		}
+		return normalizedMap
+	case []interface{}:
+		normalizedList := make([]interface{}, len(value))
+		for i, item := range value {
+			normalizedList[i] = NormalizeData(item)
+		}
+		return normalizedList
+	}
+	return data
 }
 
 // ParseYamlRecord parses yaml document content
@@ -136,7 +315,37 @@ func ParseYamlRecord(content []byte) (*DataRecord, bool) {
 	}
 	dataRecord.Columns = make(map[string]DataColumn)
 	for columnName, columnValue := range verificationMap {
-		dataRecord.Columns[columnName] = DataColumn{ColumnData: columnValue}
+		dataRecord.Columns[columnName] = DataColumn{ColumnData: NormalizeData(columnValue)}
 	}
 	return &dataRecord, true
 }
+
+// ParseYamlColumnNames returns column names of yaml document content,
+// in the order they appear in the document
+func ParseYamlColumnNames(content []byte) ([]string, bool) {
+	var verificationMap yaml.MapSlice
+	if err := yaml.Unmarshal(content, &verificationMap); err != nil {
+		fmt.Printf("1.1 >> Error while parsing data: (%v)\n", err)
+		return nil, false
+	}
+	columnNames := make([]string, 0, len(verificationMap))
+	for _, item := range verificationMap {
+		columnNames = append(columnNames, fmt.Sprint(item.Key))
+	}
+	return columnNames, true
+}
+
+// ParseYamlRowIDs returns every row-id found in yaml document content,
+// including row-ids of deleted rows
+func ParseYamlRowIDs(content []byte) (map[string]bool, bool) {
+	var verificationMap yaml.MapSlice
+	if err := yaml.Unmarshal(content, &verificationMap); err != nil {
+		fmt.Printf("1.1 >> Error while parsing data: (%v)\n", err)
+		return nil, false
+	}
+	rowIDs := make(map[string]bool, len(verificationMap))
+	for _, item := range verificationMap {
+		rowIDs[fmt.Sprint(item.Key)] = true
+	}
+	return rowIDs, true
+}
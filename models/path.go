@@ -0,0 +1,110 @@
+package models
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// Column path addresses data nested inside a column,
+// map keys are separated by '.' and list items are addressed by '[index]',
+// e.g. "address.city", "tags[0]" or "orders[1].items[0].name"
+
+// ErrInvalidPath returned when column path cannot be parsed
+var ErrInvalidPath = errors.New("INVALID COLUMN PATH")
+
+// pathSegment is either a map key or a list index
+type pathSegment struct {
+	key     string
+	index   int
+	isIndex bool
+}
+
+func parseColumnPath(path string) ([]pathSegment, error) {
+	var segments []pathSegment
+	for _, part := range strings.Split(path, ".") {
+		key := part
+		var indexes []int
+		if bracket := strings.Index(part, "["); bracket != -1 {
+			key = part[:bracket]
+			rest := part[bracket:]
+			for rest != "" {
+				closing := strings.Index(rest, "]")
+				if rest[0] != '[' || closing == -1 {
+					return nil, ErrInvalidPath
+				}
+				index, err := strconv.Atoi(rest[1:closing])
+				if err != nil || index < 0 {
+					return nil, ErrInvalidPath
+				}
+				indexes = append(indexes, index)
+				rest = rest[closing+1:]
+			}
+		}
+		if key == "" && (len(segments) == 0 || len(indexes) == 0) {
+			return nil, ErrInvalidPath
+		}
+		if key != "" {
+			segments = append(segments, pathSegment{key: key})
+		}
+		for _, index := range indexes {
+			segments = append(segments, pathSegment{index: index, isIndex: true})
+		}
+	}
+	return segments, nil
+}
+
+// ValidateColumnPath checks if column path can be parsed
+func ValidateColumnPath(path string) error {
+	_, err := parseColumnPath(path)
+	return err
+}
+
+// ColumnPathRoot returns name of the column holding data addressed by column path
+func ColumnPathRoot(path string) (string, error) {
+	segments, err := parseColumnPath(path)
+	if err != nil {
+		return "", err
+	}
+	if len(segments) == 0 || segments[0].isIndex {
+		return "", ErrInvalidPath
+	}
+	return segments[0].key, nil
+}
+
+// Lookup returns data addressed by column path in record,
+// a column whose name matches path exactly is preferred over nested data
+func (record DataRecord) Lookup(path string) (DataColumn, bool) {
+	if column, ok := record.Columns[path]; ok {
+		return column, true
+	}
+
+	segments, err := parseColumnPath(path)
+	if err != nil || len(segments) == 0 || segments[0].isIndex {
+		return DataColumn{}, false
+	}
+	column, ok := record.Columns[segments[0].key]
+	if !ok {
+		return DataColumn{}, false
+	}
+
+	data := column.ColumnData
+	for _, segment := range segments[1:] {
+		if segment.isIndex {
+			list, ok := data.([]interface{})
+			if !ok || segment.index >= len(list) {
+				return DataColumn{}, false
+			}
+			data = list[segment.index]
+			continue
+		}
+		nestedMap, ok := data.(map[string]interface{})
+		if !ok {
+			return DataColumn{}, false
+		}
+		if data, ok = nestedMap[segment.key]; !ok {
+			return DataColumn{}, false
+		}
+	}
+	return DataColumn{ColumnData: data}, true
+}
@@ -0,0 +1,183 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// Column types, a column with no type accepts data of any type
+const (
+	ColumnString    = "string"
+	ColumnInt       = "int"
+	ColumnFloat     = "float"
+	ColumnBool      = "bool"
+	ColumnTimestamp = "timestamp"
+	ColumnList      = "list"
+	ColumnMap       = "map"
+)
+
+var columnTypes = map[string]bool{
+	ColumnString:    true,
+	ColumnInt:       true,
+	ColumnFloat:     true,
+	ColumnBool:      true,
+	ColumnTimestamp: true,
+	ColumnList:      true,
+	ColumnMap:       true,
+}
+
+// timestampLayouts layouts accepted for timestamp columns
+var timestampLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+}
+
+// ColumnSchema table column definition
+type ColumnSchema struct {
+	Name     string      `yaml:"name"`
+	Type     string      `yaml:"type,omitempty"`
+	Required bool        `yaml:"required,omitempty"`
+	Default  interface{} `yaml:"default,omitempty"`
+}
+
+// CheckData checks if data is valid for column type
+func (column ColumnSchema) CheckData(data interface{}) error {
+	var valid bool
+	switch value := NormalizeData(data).(type) {
+	case string:
+		switch column.Type {
+		case ColumnString:
+			valid = true
+		case ColumnTimestamp:
+			for _, layout := range timestampLayouts {
+				if _, err := time.Parse(layout, value); err == nil {
+					valid = true
+					break
+				}
+			}
+		}
+	case int, int64, uint64:
+		valid = column.Type == ColumnInt || column.Type == ColumnFloat
+	case float64:
+		valid = column.Type == ColumnFloat
+	case bool:
+		valid = column.Type == ColumnBool
+	case time.Time:
+		valid = column.Type == ColumnTimestamp
+	case []interface{}:
+		valid = column.Type == ColumnList
+	case map[string]interface{}:
+		valid = column.Type == ColumnMap
+	}
+	if column.Type == "" || valid {
+		return nil
+	}
+	return fmt.Errorf("INVALID VALUE '%v' FOR COLUMN '%s', EXPECTED %s", DataColumn{ColumnData: NormalizeData(data)}, column.Name, strings.ToUpper(column.Type))
+}
+
+// TableSchema table definition, columns are kept in their declared order
+type TableSchema struct {
+	Columns []ColumnSchema `yaml:"columns"`
+	// RowID strategy used to generate row-ids of table
+	RowID string `yaml:"row_id,omitempty"`
+	// RowSequence number of row-ids generated for table so far
+	RowSequence uint64 `yaml:"row_sequence,omitempty"`
+}
+
+// Column returns column definition by name
+func (schema *TableSchema) Column(columnName string) (ColumnSchema, bool) {
+	for _, column := range schema.Columns {
+		if column.Name == columnName {
+			return column, true
+		}
+	}
+	return ColumnSchema{}, false
+}
+
+// Validate checks if column definitions of schema are valid
+func (schema *TableSchema) Validate() error {
+	knownColumns := make(map[string]bool)
+	for _, column := range schema.Columns {
+		if column.Name == "" {
+			return errors.New("INVALID SCHEMA, COLUMN WITH NO NAME")
+		}
+		if knownColumns[column.Name] {
+			return fmt.Errorf("INVALID SCHEMA, COLUMN '%s' DECLARED MORE THAN ONCE", column.Name)
+		}
+		knownColumns[column.Name] = true
+		if column.Type != "" && !columnTypes[column.Type] {
+			return fmt.Errorf("INVALID SCHEMA, UNKNOWN TYPE '%s' FOR COLUMN '%s'", column.Type, column.Name)
+		}
+		if column.Default != nil {
+			if err := column.CheckData(column.Default); err != nil {
+				return fmt.Errorf("INVALID SCHEMA, DEFAULT VALUE OF COLUMN '%s' IS NOT %s", column.Name, strings.ToUpper(column.Type))
+			}
+		}
+	}
+	return nil
+}
+
+// ColumnNames returns column names in their declared order
+func (schema *TableSchema) ColumnNames() []string {
+	columnNames := make([]string, 0, len(schema.Columns))
+	for _, column := range schema.Columns {
+		columnNames = append(columnNames, column.Name)
+	}
+	return columnNames
+}
+
+// HasColumn checks if column is declared in schema
+func (schema *TableSchema) HasColumn(columnName string) bool {
+	_, ok := schema.Column(columnName)
+	return ok
+}
+
+// AddColumns declares columns missing from schema, after the existing ones
+func (schema *TableSchema) AddColumns(columnNames ...string) {
+	for _, columnName := range columnNames {
+		if !schema.HasColumn(columnName) {
+			schema.Columns = append(schema.Columns, ColumnSchema{Name: columnName})
+		}
+	}
+}
+
+// DropColumn removes column from schema
+func (schema *TableSchema) DropColumn(columnName string) {
+	for i, column := range schema.Columns {
+		if column.Name == columnName {
+			schema.Columns = append(schema.Columns[:i], schema.Columns[i+1:]...)
+			return
+		}
+	}
+}
+
+// RenameColumn renames column of schema, keeping its position
+func (schema *TableSchema) RenameColumn(columnName string, newColumnName string) {
+	for i, column := range schema.Columns {
+		if column.Name == columnName {
+			schema.Columns[i].Name = newColumnName
+			return
+		}
+	}
+}
+
+// ToYaml returns yaml representation of schema
+func (schema *TableSchema) ToYaml() ([]byte, error) {
+	return yaml.Marshal(schema)
+}
+
+// ParseTableSchema parses yaml schema content
+func ParseTableSchema(content []byte) (*TableSchema, bool) {
+	var schema TableSchema
+	if err := yaml.UnmarshalStrict(content, &schema); err != nil {
+		fmt.Printf("Error while parsing schema: (%v)\n", err)
+		return nil, false
+	}
+	return &schema, true
+}
@@ -0,0 +1,189 @@
+package query
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Query language is a small SQL dialect reading rows of a table
+// [EXPLAIN] SELECT * | <item> [AS <alias>] [, <item> [AS <alias>] ...]
+//   FROM <db-name>:<table-name> [[AS] <alias>]
+//   [[INNER|LEFT [OUTER]] JOIN <db-name>:<table-name> [[AS] <alias>] ON <column> = <column> ...]
+//   [WHERE <condition>]
+//   [GROUP BY <column> [, <column> ...]]
+//   [ORDER BY <item> [ASC|DESC] [, <item> [ASC|DESC] ...]]
+//   [LIMIT <rows> [OFFSET <rows>]]
+// An item is a column, which can address nested data, e.g. address.city or tags[0],
+// or an aggregate function, e.g. count(*) or sum(total). Once tables are joined or given an alias,
+// columns are qualified by the alias or name of their table, e.g. orders.total, and can be
+// left unqualified when only one of the tables has them.
+// Conditions compare columns and values with =, !=, <>, <, <=, >, >=, LIKE and IS [NOT] NULL,
+// combined with AND, OR, NOT and parentheses. Values are 'strings', numbers, TRUE, FALSE and NULL,
+// names that are keywords or hold other characters are written in double quotes, e.g. "order"
+
+// Comparison operators
+const (
+	OpEqual        = "="
+	OpNotEqual     = "!="
+	OpLess         = "<"
+	OpLessEqual    = "<="
+	OpGreater      = ">"
+	OpGreaterEqual = ">="
+	OpLike         = "LIKE"
+)
+
+// Logical operators
+const (
+	OpAnd = "AND"
+	OpOr  = "OR"
+)
+
+// Expression node of a parsed query
+type Expression interface {
+	String() string
+}
+
+// Column references data of a row by column path
+type Column struct {
+	Path string
+}
+
+// Literal value written in query, a string, int64, float64, bool or nil
+type Literal struct {
+	Value interface{}
+}
+
+// Function aggregate function call, Column is nil for count(*)
+type Function struct {
+	Name   string
+	Column *Column
+}
+
+// Binary comparison or logical operation
+type Binary struct {
+	Operator string
+	Left     Expression
+	Right    Expression
+}
+
+// Not negates a condition
+type Not struct {
+	Operand Expression
+}
+
+// IsNull checks if operand is null or missing, or the opposite when Negated
+type IsNull struct {
+	Operand Expression
+	Negated bool
+}
+
+// SelectItem expression listed by SELECT, along with the column name it is listed under
+type SelectItem struct {
+	Expression Expression
+	Alias      string
+}
+
+// OrderItem expression rows are ordered by
+type OrderItem struct {
+	Expression Expression
+	Descending bool
+}
+
+// Join kinds
+const (
+	JoinInner = "INNER"
+	JoinLeft  = "LEFT"
+)
+
+// Join table joined to rows of the tables before it, a left join keeps rows
+// no row of the joined table matches
+type Join struct {
+	Kind  string
+	Table string
+	Alias string
+	On    Expression
+}
+
+// Statement parsed SELECT statement
+type Statement struct {
+	// Explain lists the plan of statement instead of executing it
+	Explain bool
+	// Items listed, nil when every column is selected by '*'
+	Items      []SelectItem
+	Table      string
+	TableAlias string
+	Joins      []Join
+	Where      Expression
+	GroupBy    []Column
+	OrderBy    []OrderItem
+	// Limit of rows listed, -1 when there is no limit
+	Limit  int
+	Offset int
+}
+
+func (column *Column) String() string {
+	return column.Path
+}
+
+func (literal *Literal) String() string {
+	switch value := literal.Value.(type) {
+	case nil:
+		return "NULL"
+	case string:
+		return "'" + strings.Replace(value, "'", "''", -1) + "'"
+	case int64:
+		return strconv.FormatInt(value, 10)
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	case bool:
+		return strings.ToUpper(strconv.FormatBool(value))
+	}
+	return ""
+}
+
+func (function *Function) String() string {
+	if function.Column == nil {
+		return function.Name + "(*)"
+	}
+	return function.Name + "(" + function.Column.Path + ")"
+}
+
+func (binary *Binary) String() string {
+	text := binary.Left.String() + " " + binary.Operator + " " + binary.Right.String()
+	if binary.Operator == OpAnd || binary.Operator == OpOr {
+		return "(" + text + ")"
+	}
+	return text
+}
+
+func (not *Not) String() string {
+	return "NOT " + not.Operand.String()
+}
+
+func (isNull *IsNull) String() string {
+	if isNull.Negated {
+		return isNull.Operand.String() + " IS NOT NULL"
+	}
+	return isNull.Operand.String() + " IS NULL"
+}
+
+// Name returns column name item is listed under
+func (item SelectItem) Name() string {
+	if item.Alias != "" {
+		return item.Alias
+	}
+	return item.Expression.String()
+}
+
+// HasAggregates checks if statement lists aggregate functions or groups rows
+func (statement *Statement) HasAggregates() bool {
+	if len(statement.GroupBy) > 0 {
+		return true
+	}
+	for _, item := range statement.Items {
+		if _, ok := item.Expression.(*Function); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,127 @@
+package query
+
+import (
+	"fmt"
+	"strings"
+)
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	// tokenIdent keywords, column paths and table names, e.g. "select", "address.city", "shop:orders"
+	tokenIdent
+	// tokenQuotedIdent identifier quoted by double quotes, never taken as keyword
+	tokenQuotedIdent
+	tokenString
+	tokenNumber
+	// tokenSymbol operators and punctuation, e.g. "<=", "(" and ","
+	tokenSymbol
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	// position of token in query text, counted in bytes from 1
+	position int
+}
+
+// keyword checks if token is the given keyword, keywords are case insensitive
+func (tok token) keyword(keyword string) bool {
+	return tok.kind == tokenIdent && strings.EqualFold(tok.text, keyword)
+}
+
+func (tok token) symbol(symbol string) bool {
+	return tok.kind == tokenSymbol && tok.text == symbol
+}
+
+func (tok token) String() string {
+	switch tok.kind {
+	case tokenEOF:
+		return "END OF QUERY"
+	case tokenString:
+		return "'" + tok.text + "'"
+	case tokenQuotedIdent:
+		return `"` + tok.text + `"`
+	}
+	return "'" + tok.text + "'"
+}
+
+func isIdentStart(char byte) bool {
+	return char == '_' || (char >= 'a' && char <= 'z') || (char >= 'A' && char <= 'Z')
+}
+
+func isDigit(char byte) bool {
+	return char >= '0' && char <= '9'
+}
+
+// isIdentPart checks if char continues an identifier, identifiers hold column paths
+// such as "orders[0].items" and table names such as "shop:orders"
+func isIdentPart(char byte) bool {
+	return isIdentStart(char) || isDigit(char) || strings.IndexByte(".[]:-", char) != -1
+}
+
+// tokenize splits query text into tokens, the last token is always tokenEOF
+func tokenize(text string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(text); {
+		char := text[i]
+		start := i
+		switch {
+		case char == ' ' || char == '\t' || char == '\n' || char == '\r':
+			i++
+			continue
+		case char == '\'' || char == '"':
+			// quotes inside quoted text are written twice, e.g. 'it''s'
+			var quoted strings.Builder
+			closed := false
+			for i++; i < len(text); i++ {
+				if text[i] == char {
+					if i+1 < len(text) && text[i+1] == char {
+						quoted.WriteByte(char)
+						i++
+						continue
+					}
+					closed = true
+					i++
+					break
+				}
+				quoted.WriteByte(text[i])
+			}
+			if !closed {
+				return nil, fmt.Errorf("SYNTAX ERROR AT POSITION %d: UNTERMINATED QUOTE", start+1)
+			}
+			kind := tokenString
+			if char == '"' {
+				kind = tokenQuotedIdent
+			}
+			tokens = append(tokens, token{kind: kind, text: quoted.String(), position: start + 1})
+			continue
+		case isDigit(char) || (char == '-' && i+1 < len(text) && (isDigit(text[i+1]) || text[i+1] == '.')) || (char == '.' && i+1 < len(text) && isDigit(text[i+1])):
+			for i++; i < len(text) && (isDigit(text[i]) || text[i] == '.' || text[i] == 'e' || text[i] == 'E' ||
+				((text[i] == '-' || text[i] == '+') && (text[i-1] == 'e' || text[i-1] == 'E'))); i++ {
+			}
+			tokens = append(tokens, token{kind: tokenNumber, text: text[start:i], position: start + 1})
+			continue
+		case isIdentStart(char):
+			for i++; i < len(text) && isIdentPart(text[i]); i++ {
+			}
+			tokens = append(tokens, token{kind: tokenIdent, text: text[start:i], position: start + 1})
+			continue
+		}
+
+		symbol := string(char)
+		if i+1 < len(text) {
+			switch text[i : i+2] {
+			case "<=", ">=", "!=", "<>":
+				symbol = text[i : i+2]
+			}
+		}
+		if strings.IndexByte("=<>!(),*", symbol[0]) == -1 || symbol == "!" {
+			return nil, fmt.Errorf("SYNTAX ERROR AT POSITION %d: UNEXPECTED CHARACTER '%c'", start+1, char)
+		}
+		tokens = append(tokens, token{kind: tokenSymbol, text: symbol, position: start + 1})
+		i += len(symbol)
+	}
+	return append(tokens, token{kind: tokenEOF, position: len(text) + 1}), nil
+}
@@ -0,0 +1,414 @@
+package query
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// keywords cannot be used as names unless written in double quotes
+var keywords = map[string]bool{
+	"EXPLAIN": true,
+	"SELECT":  true,
+	"FROM":    true,
+	"WHERE":   true,
+	"GROUP":   true,
+	"ORDER":   true,
+	"BY":      true,
+	"ASC":     true,
+	"DESC":    true,
+	"LIMIT":   true,
+	"OFFSET":  true,
+	"AS":      true,
+	"AND":     true,
+	"OR":      true,
+	"NOT":     true,
+	"IS":      true,
+	"NULL":    true,
+	"TRUE":    true,
+	"FALSE":   true,
+	"LIKE":    true,
+	"JOIN":    true,
+	"INNER":   true,
+	"LEFT":    true,
+	"OUTER":   true,
+	"ON":      true,
+}
+
+type parser struct {
+	tokens []token
+	next   int
+}
+
+// Parse parses query text into a statement
+func Parse(text string) (*Statement, error) {
+	tokens, err := tokenize(text)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	return p.parseStatement()
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.next]
+}
+
+func (p *parser) advance() token {
+	tok := p.tokens[p.next]
+	if tok.kind != tokenEOF {
+		p.next++
+	}
+	return tok
+}
+
+func (p *parser) errorf(tok token, format string, args ...interface{}) error {
+	return fmt.Errorf("SYNTAX ERROR AT POSITION %d: %s", tok.position, fmt.Sprintf(format, args...))
+}
+
+// acceptKeyword takes the next token when it is the keyword
+func (p *parser) acceptKeyword(keyword string) bool {
+	if p.peek().keyword(keyword) {
+		p.advance()
+		return true
+	}
+	return false
+}
+
+func (p *parser) expectKeyword(keyword string) error {
+	if !p.acceptKeyword(keyword) {
+		return p.errorf(p.peek(), "EXPECTED %s, FOUND %s", keyword, p.peek())
+	}
+	return nil
+}
+
+func (p *parser) acceptSymbol(symbol string) bool {
+	if p.peek().symbol(symbol) {
+		p.advance()
+		return true
+	}
+	return false
+}
+
+func (p *parser) expectSymbol(symbol string) error {
+	if !p.acceptSymbol(symbol) {
+		return p.errorf(p.peek(), "EXPECTED '%s', FOUND %s", symbol, p.peek())
+	}
+	return nil
+}
+
+// parseName parses a name that is not a keyword, or any name in double quotes
+func (p *parser) parseName(what string) (string, error) {
+	tok := p.peek()
+	if tok.kind == tokenQuotedIdent || (tok.kind == tokenIdent && !keywords[strings.ToUpper(tok.text)]) {
+		p.advance()
+		return tok.text, nil
+	}
+	return "", p.errorf(tok, "EXPECTED %s, FOUND %s", what, tok)
+}
+
+func (p *parser) parseStatement() (*Statement, error) {
+	statement := &Statement{Limit: -1}
+	var err error
+	statement.Explain = p.acceptKeyword("EXPLAIN")
+	if err := p.expectKeyword("SELECT"); err != nil {
+		return nil, err
+	}
+
+	if !p.acceptSymbol("*") {
+		for {
+			item, err := p.parseSelectItem()
+			if err != nil {
+				return nil, err
+			}
+			statement.Items = append(statement.Items, item)
+			if !p.acceptSymbol(",") {
+				break
+			}
+		}
+	}
+
+	if err := p.expectKeyword("FROM"); err != nil {
+		return nil, err
+	}
+	if statement.Table, statement.TableAlias, err = p.parseTable(); err != nil {
+		return nil, err
+	}
+	for {
+		join := Join{Kind: JoinInner}
+		if p.acceptKeyword(JoinLeft) {
+			join.Kind = JoinLeft
+			p.acceptKeyword("OUTER")
+		} else if !p.acceptKeyword(JoinInner) && !p.peek().keyword("JOIN") {
+			break
+		}
+		if err := p.expectKeyword("JOIN"); err != nil {
+			return nil, err
+		}
+		if join.Table, join.Alias, err = p.parseTable(); err != nil {
+			return nil, err
+		}
+		if err := p.expectKeyword("ON"); err != nil {
+			return nil, err
+		}
+		if join.On, err = p.parseOr(); err != nil {
+			return nil, err
+		}
+		statement.Joins = append(statement.Joins, join)
+	}
+
+	if p.acceptKeyword("WHERE") {
+		if statement.Where, err = p.parseOr(); err != nil {
+			return nil, err
+		}
+	}
+	if p.acceptKeyword("GROUP") {
+		if err := p.expectKeyword("BY"); err != nil {
+			return nil, err
+		}
+		for {
+			column, err := p.parseColumn()
+			if err != nil {
+				return nil, err
+			}
+			statement.GroupBy = append(statement.GroupBy, *column)
+			if !p.acceptSymbol(",") {
+				break
+			}
+		}
+	}
+	if p.acceptKeyword("ORDER") {
+		if err := p.expectKeyword("BY"); err != nil {
+			return nil, err
+		}
+		for {
+			expression, err := p.parseItemExpression()
+			if err != nil {
+				return nil, err
+			}
+			item := OrderItem{Expression: expression}
+			if p.acceptKeyword("DESC") {
+				item.Descending = true
+			} else {
+				p.acceptKeyword("ASC")
+			}
+			statement.OrderBy = append(statement.OrderBy, item)
+			if !p.acceptSymbol(",") {
+				break
+			}
+		}
+	}
+	if p.acceptKeyword("LIMIT") {
+		if statement.Limit, err = p.parseCount("LIMIT"); err != nil {
+			return nil, err
+		}
+	}
+	if p.acceptKeyword("OFFSET") {
+		if statement.Offset, err = p.parseCount("OFFSET"); err != nil {
+			return nil, err
+		}
+	}
+
+	if tok := p.peek(); tok.kind != tokenEOF {
+		return nil, p.errorf(tok, "UNEXPECTED %s", tok)
+	}
+	return statement, nil
+}
+
+// parseTable parses table name followed by an optional alias
+func (p *parser) parseTable() (string, string, error) {
+	table, err := p.parseName("TABLE-NAME")
+	if err != nil {
+		return "", "", err
+	}
+	alias := ""
+	if p.acceptKeyword("AS") || p.peek().kind == tokenQuotedIdent ||
+		(p.peek().kind == tokenIdent && !keywords[strings.ToUpper(p.peek().text)]) {
+		if alias, err = p.parseName("ALIAS"); err != nil {
+			return "", "", err
+		}
+	}
+	return table, alias, nil
+}
+
+// parseCount parses number of rows given to LIMIT or OFFSET
+func (p *parser) parseCount(clause string) (int, error) {
+	tok := p.advance()
+	count, err := strconv.Atoi(tok.text)
+	if tok.kind != tokenNumber || err != nil || count < 0 {
+		return 0, p.errorf(tok, "%s SHOULD BE A NUMBER OF ROWS, FOUND %s", clause, tok)
+	}
+	return count, nil
+}
+
+func (p *parser) parseSelectItem() (SelectItem, error) {
+	expression, err := p.parseItemExpression()
+	if err != nil {
+		return SelectItem{}, err
+	}
+	item := SelectItem{Expression: expression}
+	if p.acceptKeyword("AS") {
+		if item.Alias, err = p.parseName("ALIAS"); err != nil {
+			return SelectItem{}, err
+		}
+	}
+	return item, nil
+}
+
+// parseItemExpression parses a column or an aggregate function
+func (p *parser) parseItemExpression() (Expression, error) {
+	tok := p.peek()
+	if tok.kind == tokenIdent && p.tokens[p.next+1].symbol("(") {
+		p.advance()
+		p.advance()
+		function := &Function{Name: strings.ToLower(tok.text)}
+		if !p.acceptSymbol("*") {
+			column, err := p.parseColumn()
+			if err != nil {
+				return nil, err
+			}
+			function.Column = column
+		}
+		if err := p.expectSymbol(")"); err != nil {
+			return nil, err
+		}
+		return function, nil
+	}
+	return p.parseColumn()
+}
+
+func (p *parser) parseColumn() (*Column, error) {
+	path, err := p.parseName("COLUMN")
+	if err != nil {
+		return nil, err
+	}
+	return &Column{Path: path}, nil
+}
+
+func (p *parser) parseOr() (Expression, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.acceptKeyword(OpOr) {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &Binary{Operator: OpOr, Left: left, Right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (Expression, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.acceptKeyword(OpAnd) {
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = &Binary{Operator: OpAnd, Left: left, Right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseNot() (Expression, error) {
+	if p.acceptKeyword("NOT") {
+		operand, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return &Not{Operand: operand}, nil
+	}
+	if p.acceptSymbol("(") {
+		expression, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expectSymbol(")"); err != nil {
+			return nil, err
+		}
+		return expression, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *parser) parseComparison() (Expression, error) {
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+
+	if p.acceptKeyword("IS") {
+		negated := p.acceptKeyword("NOT")
+		if err := p.expectKeyword("NULL"); err != nil {
+			return nil, err
+		}
+		return &IsNull{Operand: left, Negated: negated}, nil
+	}
+	if p.acceptKeyword("NOT") {
+		if err := p.expectKeyword(OpLike); err != nil {
+			return nil, err
+		}
+		right, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		return &Not{Operand: &Binary{Operator: OpLike, Left: left, Right: right}}, nil
+	}
+
+	tok := p.advance()
+	operator := ""
+	switch {
+	case tok.keyword(OpLike):
+		operator = OpLike
+	case tok.kind == tokenSymbol:
+		switch tok.text {
+		case "=", "!=", "<", "<=", ">", ">=":
+			operator = tok.text
+		case "<>":
+			operator = OpNotEqual
+		}
+	}
+	if operator == "" {
+		return nil, p.errorf(tok, "EXPECTED COMPARISON OPERATOR, FOUND %s", tok)
+	}
+	right, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	return &Binary{Operator: operator, Left: left, Right: right}, nil
+}
+
+// parseOperand parses a column or a literal value
+func (p *parser) parseOperand() (Expression, error) {
+	tok := p.peek()
+	switch {
+	case tok.kind == tokenString:
+		p.advance()
+		return &Literal{Value: tok.text}, nil
+	case tok.kind == tokenNumber:
+		p.advance()
+		if value, err := strconv.ParseInt(tok.text, 10, 64); err == nil {
+			return &Literal{Value: value}, nil
+		}
+		value, err := strconv.ParseFloat(tok.text, 64)
+		if err != nil {
+			return nil, p.errorf(tok, "INVALID NUMBER %s", tok)
+		}
+		return &Literal{Value: value}, nil
+	case tok.keyword("TRUE"):
+		p.advance()
+		return &Literal{Value: true}, nil
+	case tok.keyword("FALSE"):
+		p.advance()
+		return &Literal{Value: false}, nil
+	case tok.keyword("NULL"):
+		p.advance()
+		return &Literal{Value: nil}, nil
+	}
+	return p.parseColumn()
+}
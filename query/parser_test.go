@@ -0,0 +1,149 @@
+package query
+
+import "testing"
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		text string
+		err  string
+	}{
+		{"", "SYNTAX ERROR AT POSITION 1: EXPECTED SELECT, FOUND END OF QUERY"},
+		{"SELECT FROM d:t", "SYNTAX ERROR AT POSITION 8: EXPECTED COLUMN, FOUND 'FROM'"},
+		{"SELECT name FROM", "SYNTAX ERROR AT POSITION 17: EXPECTED TABLE-NAME, FOUND END OF QUERY"},
+		{"SELECT * FROM d:t WHERE age >", "SYNTAX ERROR AT POSITION 30: EXPECTED COLUMN, FOUND END OF QUERY"},
+		{"SELECT * FROM d:t WHERE age ~ 3", "SYNTAX ERROR AT POSITION 29: UNEXPECTED CHARACTER '~'"},
+		{"SELECT * FROM d:t WHERE name = 'ann", "SYNTAX ERROR AT POSITION 32: UNTERMINATED QUOTE"},
+		{"SELECT * FROM d:t WHERE name NOT = 'ann'", "SYNTAX ERROR AT POSITION 34: EXPECTED LIKE, FOUND '='"},
+		{"SELECT * FROM d:t WHERE (age > 3", "SYNTAX ERROR AT POSITION 33: EXPECTED ')', FOUND END OF QUERY"},
+		{"SELECT * FROM d:t ORDER name", "SYNTAX ERROR AT POSITION 25: EXPECTED BY, FOUND 'name'"},
+		{"SELECT * FROM d:t LIMIT -1", "SYNTAX ERROR AT POSITION 25: LIMIT SHOULD BE A NUMBER OF ROWS, FOUND '-1'"},
+		{"SELECT * FROM d:t LIMIT 5 OFFSET x", "SYNTAX ERROR AT POSITION 34: OFFSET SHOULD BE A NUMBER OF ROWS, FOUND 'x'"},
+		{"SELECT * FROM d:t t extra", "SYNTAX ERROR AT POSITION 21: UNEXPECTED 'extra'"},
+		{"SELECT * FROM d:t JOIN d:u", "SYNTAX ERROR AT POSITION 27: EXPECTED ON, FOUND END OF QUERY"},
+	}
+	for _, test := range tests {
+		_, err := Parse(test.text)
+		if err == nil {
+			t.Errorf("Parse(%q) succeeded, expected error %q", test.text, test.err)
+			continue
+		}
+		if err.Error() != test.err {
+			t.Errorf("Parse(%q) failed with %q, expected %q", test.text, err, test.err)
+		}
+	}
+}
+
+func TestParseKeywordsAsNames(t *testing.T) {
+	tests := []struct {
+		text       string
+		item       string
+		alias      string
+		tableAlias string
+		orderBy    int
+		err        string
+	}{
+		{text: `SELECT name AS "select" FROM d:t`, item: "name", alias: "select"},
+		{text: `SELECT "from" FROM d:t`, item: "from"},
+		{text: `SELECT name FROM d:t "order"`, item: "name", tableAlias: "order"},
+		{text: `SELECT name FROM d:t AS "where"`, item: "name", tableAlias: "where"},
+		{text: `SELECT name FROM d:t o`, item: "name", tableAlias: "o"},
+		// keywords after the table are clauses, not its alias
+		{text: `SELECT name FROM d:t order by name`, item: "name", orderBy: 1},
+		{text: `SELECT name AS select FROM d:t`, err: "SYNTAX ERROR AT POSITION 16: EXPECTED ALIAS, FOUND 'select'"},
+		{text: `SELECT name FROM d:t AS where`, err: "SYNTAX ERROR AT POSITION 25: EXPECTED ALIAS, FOUND 'where'"},
+		{text: `SELECT order FROM d:t`, err: "SYNTAX ERROR AT POSITION 8: EXPECTED COLUMN, FOUND 'order'"},
+	}
+	for _, test := range tests {
+		statement, err := Parse(test.text)
+		if test.err != "" {
+			if err == nil || err.Error() != test.err {
+				t.Errorf("Parse(%q) returned error %v, expected %q", test.text, err, test.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Parse(%q) failed: %v", test.text, err)
+			continue
+		}
+		if len(statement.Items) != 1 || statement.Items[0].Expression.String() != test.item || statement.Items[0].Alias != test.alias {
+			t.Errorf("Parse(%q) listed %v, expected %s aliased %q", test.text, statement.Items, test.item, test.alias)
+		}
+		if statement.TableAlias != test.tableAlias {
+			t.Errorf("Parse(%q) aliased table %q, expected %q", test.text, statement.TableAlias, test.tableAlias)
+		}
+		if len(statement.OrderBy) != test.orderBy {
+			t.Errorf("Parse(%q) ordered by %d items, expected %d", test.text, len(statement.OrderBy), test.orderBy)
+		}
+	}
+}
+
+func TestParseConditions(t *testing.T) {
+	tests := []struct {
+		where    string
+		expected string
+	}{
+		{"name NOT LIKE 'a%'", "NOT name LIKE 'a%'"},
+		{"NOT name LIKE 'a%'", "NOT name LIKE 'a%'"},
+		{"name not like 'a%' AND age > 3", "(NOT name LIKE 'a%' AND age > 3)"},
+		{"NOT name NOT LIKE 'a%'", "NOT NOT name LIKE 'a%'"},
+		{"name LIKE 'it''s%'", "name LIKE 'it''s%'"},
+		{"age <> 3 OR age >= 10 AND age < 20", "(age != 3 OR (age >= 10 AND age < 20))"},
+		{"(age <> 3 OR age >= 10) AND age < 20", "((age != 3 OR age >= 10) AND age < 20)"},
+		{"3 < age", "3 < age"},
+		{"address.city IS NOT NULL", "address.city IS NOT NULL"},
+		{"tags[0] IS NULL", "tags[0] IS NULL"},
+		{"score = -1.5", "score = -1.5"},
+		{"active = TRUE AND deleted = false", "(active = TRUE AND deleted = FALSE)"},
+		{`"order" = 'x'`, "order = 'x'"},
+	}
+	for _, test := range tests {
+		statement, err := Parse("SELECT * FROM d:t WHERE " + test.where)
+		if err != nil {
+			t.Errorf("Parse(%q) failed: %v", test.where, err)
+			continue
+		}
+		if where := statement.Where.String(); where != test.expected {
+			t.Errorf("Parse(%q) parsed condition %s, expected %s", test.where, where, test.expected)
+		}
+	}
+}
+
+func TestParseStatement(t *testing.T) {
+	statement, err := Parse("explain select o.id, count(*) AS n, sum(total) from shop:orders o " +
+		"left outer join shop:users AS u on o.user = u.id inner join shop:items i on i.order = o.id " +
+		"where total > 10 group by o.id order by n desc, o.id limit 5 offset 2")
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if !statement.Explain || statement.Table != "shop:orders" || statement.TableAlias != "o" {
+		t.Errorf("Parse read explain %v, table %s aliased %s", statement.Explain, statement.Table, statement.TableAlias)
+	}
+	var names []string
+	for _, item := range statement.Items {
+		names = append(names, item.Name())
+	}
+	if len(names) != 3 || names[0] != "o.id" || names[1] != "n" || names[2] != "sum(total)" {
+		t.Errorf("Parse listed %v", names)
+	}
+	if len(statement.Joins) != 2 || statement.Joins[0].Kind != JoinLeft || statement.Joins[0].Alias != "u" ||
+		statement.Joins[1].Kind != JoinInner || statement.Joins[1].On.String() != "i.order = o.id" {
+		t.Errorf("Parse joined %v", statement.Joins)
+	}
+	if len(statement.GroupBy) != 1 || statement.GroupBy[0].Path != "o.id" {
+		t.Errorf("Parse grouped by %v", statement.GroupBy)
+	}
+	if len(statement.OrderBy) != 2 || !statement.OrderBy[0].Descending || statement.OrderBy[1].Descending {
+		t.Errorf("Parse ordered by %v", statement.OrderBy)
+	}
+	if statement.Limit != 5 || statement.Offset != 2 || !statement.HasAggregates() {
+		t.Errorf("Parse read limit %d, offset %d", statement.Limit, statement.Offset)
+	}
+
+	statement, err = Parse("SELECT * FROM d:t")
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if statement.Items != nil || statement.Limit != -1 || statement.HasAggregates() {
+		t.Errorf("Parse read items %v, limit %d", statement.Items, statement.Limit)
+	}
+}
@@ -3,17 +3,21 @@ package main
 import (
 	"bufio"
 	"fmt"
+	"io"
 	"net"
 	"os"
 	"strconv"
-	"strings"
-	// only needed below for sample processing
+
 	"github.com/sushilkm/myYamlDB/common"
 	"github.com/sushilkm/myYamlDB/engine"
 )
 
-func portAvailable(port int) bool {
-	conn, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", port))
+func portAvailable(bindAddress string, port int) bool {
+	host := bindAddress
+	if host == "" {
+		host = "127.0.0.1"
+	}
+	conn, err := net.Listen("tcp", net.JoinHostPort(host, strconv.Itoa(port)))
 	if err != nil {
 		return false
 	}
@@ -21,64 +25,150 @@ func portAvailable(port int) bool {
 	return true
 }
 
-var dbObject = engine.DBEngine{}
+// config settings server was started with
+var config *common.Config
+
+// logf logs message when level is enabled by configured log level
+func logf(level string, format string, args ...interface{}) {
+	if config.LogEnabled(level) {
+		fmt.Printf(format, args...)
+	}
+}
 
 func main() {
 
-	var port = strconv.Itoa(common.DBPort)
-	commandLineArgs := os.Args
-	if len(commandLineArgs) > 2 {
-		fmt.Println("USAGE: myYamlDB <port>")
-		return
-	}
-	if len(commandLineArgs) >= 2 {
-		port = commandLineArgs[1]
+	var err error
+	config, err = common.LoadConfig(os.Args[1:])
+	if err != nil {
+		fmt.Printf("Failed to load config: (%v)\n", err)
+		fmt.Println("USAGE: myYamlDB " + common.ConfigUsage())
+		os.Exit(1)
 	}
 
-	intPort, err := strconv.Atoi(port)
+	storage, err := engine.NewStorage(config.Storage, config.DataDir)
 	if err != nil {
-		fmt.Println("DB port should be a number")
+		fmt.Println(err)
 		os.Exit(1)
 	}
-	if !portAvailable(intPort) {
+	engine.SetStorage(storage)
+	engine.SetTableCacheSize(config.TableCacheSize)
+	engine.SetConfig(config)
+	engine.SetBackupDir(config.BackupDir)
+	engine.SetChangeLog(config.ChangeLogDir, config.ChangeLogSegmentSize, config.ChangeLogSegments)
+
+	if !portAvailable(config.BindAddress, config.Port) {
 		fmt.Println("DB port is already in USE")
 		os.Exit(1)
 	}
 
-	fmt.Println("Launching server...")
+	// take data directory over, no other server process can use it meanwhile
+	if err := engine.OpenStorage(); err != nil {
+		fmt.Printf("Failed to open storage: (%v)\n", err)
+		os.Exit(1)
+	}
+
+	// apply appends left in write-ahead logs by a crash before serving clients
+	if err := engine.RecoverDatabases(); err != nil {
+		fmt.Printf("Failed to recover databases: (%v)\n", err)
+		os.Exit(1)
+	}
+
+	if config.CompactInterval > 0 {
+		engine.StartCompactor(config.CompactInterval)
+	}
 
-	// listen on all interfaces
-	ln, _ := net.Listen("tcp", ":"+port)
+	logf(common.LogInfo, "Launching server...\n")
 
-	// accept connection on port
-	conn, _ := ln.Accept()
+	// listen on bind address, all interfaces when not set
+	ln, err := net.Listen("tcp", net.JoinHostPort(config.BindAddress, strconv.Itoa(config.Port)))
+	if err != nil {
+		fmt.Printf("Failed to listen: (%v)\n", err)
+		os.Exit(1)
+	}
+
+	// connections holds a slot for every connection served, when connections are limited
+	var connections chan bool
+	if config.MaxConnections > 0 {
+		connections = make(chan bool, config.MaxConnections)
+	}
 
-	// run loop forever (or until ctrl-c)
+	// accept connections forever (or until ctrl-c),
+	// every connection is served in its own goroutine
 	for {
-		// will listen for message to process ending in newline (\n)
-		message, err := bufio.NewReader(conn).ReadString('\n')
-		if err != nil && err.Error() == "EOF" {
-			conn.Close()
-			conn, _ = ln.Accept()
+		conn, err := ln.Accept()
+		if err != nil {
+			logf(common.LogError, "Failed to accept connection: (%v)\n", err)
+			continue
+		}
+		if connections == nil {
+			go handleConnection(conn)
 			continue
 		}
-		fmt.Print("Received command:", string(message))
+		select {
+		case connections <- true:
+			go func() {
+				defer func() { <-connections }()
+				handleConnection(conn)
+			}()
+		default:
+			logf(common.LogWarn, "Rejected connection from %s: too many connections\n", conn.RemoteAddr())
+			common.WriteFrame(conn, common.ProtocolError, []byte("TOO MANY CONNECTIONS"))
+			conn.Close()
+		}
+	}
+}
+
+func handleConnection(conn net.Conn) {
+	defer conn.Close()
+	logf(common.LogInfo, "Client connected: %s\n", conn.RemoteAddr())
+	defer logf(common.LogInfo, "Client disconnected: %s\n", conn.RemoteAddr())
+
+	reader := bufio.NewReader(conn)
+
+	// first frame from client is the protocol handshake
+	header, _, err := common.ReadFrame(reader)
+	if err != nil {
+		logf(common.LogError, "Error while reading handshake from %s: (%v)\n", conn.RemoteAddr(), err)
+		return
+	}
+	if err := common.CheckHandshake(header); err != nil {
+		logf(common.LogWarn, "Rejected handshake from %s: (%v)\n", conn.RemoteAddr(), err)
+		common.WriteFrame(conn, common.ProtocolError, []byte(err.Error()))
+		return
+	}
+	if err := common.WriteFrame(conn, common.ProtocolOK, []byte(common.HandshakeHeader())); err != nil {
+		logf(common.LogError, "Error while writing to %s: (%v)\n", conn.RemoteAddr(), err)
+		return
+	}
 
-		// sample process for string received
-		newmessage := strings.ToUpper(message)
+	// every connection keeps its own engine state
+	dbObject := engine.DBEngine{}
+	for {
+		// will listen for command frames, header is the command and payload its document
+		message, payload, err := common.ReadFrame(reader)
+		if err != nil {
+			if err != io.EOF {
+				logf(common.LogError, "Error while reading from %s: (%v)\n", conn.RemoteAddr(), err)
+			}
+			return
+		}
+		logf(common.LogDebug, "Received command from %s: %s (%d bytes of payload)\n", conn.RemoteAddr(), message, len(payload))
 
-		err = dbObject.MakeCommand(string(message))
+		status, newmessage := common.ProtocolOK, ""
+		err = dbObject.MakeCommand(message, payload)
 		if err != nil {
-			fmt.Println(err.Error())
-			newmessage = err.Error()
+			logf(common.LogDebug, "%s\n", err.Error())
+			status, newmessage = common.ProtocolError, err.Error()
 		} else if output, err := dbObject.ExecuteCommand(); err != nil {
-			fmt.Println(err.Error())
-			newmessage = err.Error()
+			logf(common.LogDebug, "%s\n", err.Error())
+			status, newmessage = common.ProtocolError, err.Error()
 		} else {
 			newmessage = output
 		}
-		// send new string back to client
-		dataLength := strconv.Itoa(len(newmessage))
-		conn.Write([]byte(dataLength + "\n" + newmessage))
+		// send response frame back to client
+		if err := common.WriteFrame(conn, status, []byte(newmessage)); err != nil {
+			logf(common.LogError, "Error while writing to %s: (%v)\n", conn.RemoteAddr(), err)
+			return
+		}
 	}
 }